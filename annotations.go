@@ -3,8 +3,11 @@ package ophis
 import (
 	"log/slog"
 	"strconv"
+	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/njayp/ophis/internal/bridge/flags"
 	"github.com/spf13/cobra"
 )
 
@@ -39,57 +42,153 @@ const (
 	AnnotationOpenWorld = "openWorldHint"
 )
 
+// Cobra command annotation keys for ophis execution settings.
+const (
+	// AnnotationTimeout overrides the execution timeout for a command.
+	// The value is parsed with time.ParseDuration (e.g. "30s", "5m").
+	AnnotationTimeout = "mcpTimeout"
+
+	// AnnotationOutput declares the format of a command's stdout.
+	// Set it to OutputFormatJSON to return stdout parsed as JSON in the result field.
+	AnnotationOutput = "mcpOutput"
+
+	// AnnotationOutputSchema sets the JSON schema of the result field for a command
+	// with JSON output. The value is the JSON representation of the schema.
+	// Results that do not match the schema are returned as text with a tool error.
+	// WithOutputType sets this from a Go type.
+	AnnotationOutputSchema = "mcpOutputSchema"
+
+	// AnnotationArgsMin sets the minimum number of positional arguments a command accepts.
+	// Use it with AnnotationArgsMax when the bounds cannot be detected from cmd.Args,
+	// such as for custom validators.
+	AnnotationArgsMin = "mcpArgsMin"
+
+	// AnnotationArgsMax sets the maximum number of positional arguments a command accepts.
+	AnnotationArgsMax = "mcpArgsMax"
+)
+
+// Cobra command annotation keys that override the generated tool.
+// Selector.Overrides takes precedence over them.
+const (
+	// AnnotationName overrides the name of the tool, including the prefix.
+	// Use it to shorten names that exceed client limits, such as Claude's 64 characters.
+	AnnotationName = "mcpName"
+
+	// AnnotationDescription overrides the description of the tool.
+	AnnotationDescription = "mcpDescription"
+)
+
+// OutputFormatJSON is the AnnotationOutput value for commands that print JSON.
+const OutputFormatJSON = "json"
+
+// FlagAnnotationSchema is the pflag.Flag annotation key for a JSON schema that is merged
+// into the generated schema of the flag, for any flag type. Keywords set in the annotation
+// replace the generated ones (shallowly); keywords it leaves out, such as the description
+// and default, are kept. SetFlagSchema sets it from a jsonschema.Schema.
+const FlagAnnotationSchema = flags.SchemaAnnotation
+
+// annotationTimeout reads AnnotationTimeout from cmd.Annotations.
+// It reports false if the annotation is missing or invalid.
+func annotationTimeout(cmd *cobra.Command) (time.Duration, bool) {
+	v, ok := cmd.Annotations[AnnotationTimeout]
+	if !ok {
+		return 0, false
+	}
+
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("invalid duration value for annotation, skipping", "key", AnnotationTimeout, "value", v)
+		return 0, false
+	}
+
+	return timeout, true
+}
+
+// resultSchema reads AnnotationOutputSchema from cmd.Annotations.
+// It returns nil if the annotation is missing or invalid.
+func resultSchema(cmd *cobra.Command) *jsonschema.Schema {
+	v, ok := cmd.Annotations[AnnotationOutputSchema]
+	if !ok {
+		return nil
+	}
+
+	var schema jsonschema.Schema
+	if err := schema.UnmarshalJSON([]byte(v)); err != nil {
+		slog.Error("invalid JSON schema for annotation, skipping", "key", AnnotationOutputSchema, "command", cmd.CommandPath(), "error", err)
+		return nil
+	}
+
+	return &schema
+}
+
+// resolvedResultSchema resolves the AnnotationOutputSchema of cmd for validation.
+// It returns nil if the command has no usable result schema.
+func resolvedResultSchema(cmd *cobra.Command) (*jsonschema.Resolved, error) {
+	schema := resultSchema(cmd)
+	if schema == nil {
+		return nil, nil
+	}
+
+	return schema.Resolve(nil)
+}
+
 // toolAnnotations reads MCP annotation keys from cmd.Annotations and
 // returns a populated *mcp.ToolAnnotations, or nil if no MCP annotations are found.
 func toolAnnotations(cmd *cobra.Command) *mcp.ToolAnnotations {
-	if len(cmd.Annotations) == 0 {
+	return parseToolAnnotations(cmd.Annotations)
+}
+
+// parseToolAnnotations reads MCP annotation keys from annotations and
+// returns a populated *mcp.ToolAnnotations, or nil if no MCP annotations are found.
+func parseToolAnnotations(annotations map[string]string) *mcp.ToolAnnotations {
+	if len(annotations) == 0 {
 		return nil
 	}
 
-	var annotations mcp.ToolAnnotations
+	var result mcp.ToolAnnotations
 	found := false
 
-	if v, ok := cmd.Annotations[AnnotationTitle]; ok {
-		annotations.Title = v
+	if v, ok := annotations[AnnotationTitle]; ok {
+		result.Title = v
 		found = true
 	}
 
-	if v, ok := cmd.Annotations[AnnotationReadOnly]; ok {
+	if v, ok := annotations[AnnotationReadOnly]; ok {
 		b, err := strconv.ParseBool(v)
 		if err != nil {
 			slog.Warn("invalid bool value for annotation, skipping", "key", AnnotationReadOnly, "value", v)
 		} else {
-			annotations.ReadOnlyHint = b
+			result.ReadOnlyHint = b
 			found = true
 		}
 	}
 
-	if v, ok := cmd.Annotations[AnnotationDestructive]; ok {
+	if v, ok := annotations[AnnotationDestructive]; ok {
 		b, err := strconv.ParseBool(v)
 		if err != nil {
 			slog.Warn("invalid bool value for annotation, skipping", "key", AnnotationDestructive, "value", v)
 		} else {
-			annotations.DestructiveHint = &b
+			result.DestructiveHint = &b
 			found = true
 		}
 	}
 
-	if v, ok := cmd.Annotations[AnnotationIdempotent]; ok {
+	if v, ok := annotations[AnnotationIdempotent]; ok {
 		b, err := strconv.ParseBool(v)
 		if err != nil {
 			slog.Warn("invalid bool value for annotation, skipping", "key", AnnotationIdempotent, "value", v)
 		} else {
-			annotations.IdempotentHint = b
+			result.IdempotentHint = b
 			found = true
 		}
 	}
 
-	if v, ok := cmd.Annotations[AnnotationOpenWorld]; ok {
+	if v, ok := annotations[AnnotationOpenWorld]; ok {
 		b, err := strconv.ParseBool(v)
 		if err != nil {
 			slog.Warn("invalid bool value for annotation, skipping", "key", AnnotationOpenWorld, "value", v)
 		} else {
-			annotations.OpenWorldHint = &b
+			result.OpenWorldHint = &b
 			found = true
 		}
 	}
@@ -98,5 +197,5 @@ func toolAnnotations(cmd *cobra.Command) *mcp.ToolAnnotations {
 		return nil
 	}
 
-	return &annotations
+	return &result
 }
@@ -0,0 +1,137 @@
+package ophis
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// argsProbeLimit is the largest argument count tried when probing a command's Args validator.
+// A validator that still accepts this many arguments is treated as unbounded.
+const argsProbeLimit = 16
+
+// invalidArgProbe is the placeholder used to test whether a command's Args validator
+// rejects arguments missing from ValidArgs. No command is expected to list it.
+const invalidArgProbe = "\x00ophis-invalid-arg"
+
+// argsRange returns the minimum and maximum number of positional arguments cmd accepts.
+// A nil max means no upper bound; ok is false when nothing is known about the count.
+//
+// The AnnotationArgsMin and AnnotationArgsMax annotations take precedence.
+// Otherwise cmd.Args is probed with placeholder arguments, which recovers the
+// bounds of cobra's built-in validators (NoArgs, ExactArgs, MinimumNArgs,
+// MaximumNArgs, RangeArgs, and their combinations with OnlyValidArgs).
+func argsRange(cmd *cobra.Command) (minArgs int, maxArgs *int, ok bool) {
+	annotatedMin, hasMin := annotationInt(cmd, AnnotationArgsMin)
+	annotatedMax, hasMax := annotationInt(cmd, AnnotationArgsMax)
+	if hasMin || hasMax {
+		if hasMax {
+			maxArgs = &annotatedMax
+		}
+		return annotatedMin, maxArgs, true
+	}
+
+	if cmd.Args == nil {
+		return 0, nil, false
+	}
+
+	accepted := make([]bool, argsProbeLimit+1)
+	found := false
+	for n := range accepted {
+		accepted[n] = probeArgs(cmd, n)
+		found = found || accepted[n]
+	}
+
+	// The validator depends on something other than the count
+	if !found {
+		return 0, nil, false
+	}
+
+	for !accepted[minArgs] {
+		minArgs++
+	}
+
+	if !accepted[argsProbeLimit] {
+		last := argsProbeLimit
+		for !accepted[last] {
+			last--
+		}
+		maxArgs = &last
+	}
+
+	return minArgs, maxArgs, true
+}
+
+// probeArgs reports whether cmd.Args accepts n placeholder arguments.
+// Placeholders are taken from ValidArgs when present, so OnlyValidArgs passes.
+func probeArgs(cmd *cobra.Command, n int) bool {
+	placeholder := "arg"
+	if valid := validArgs(cmd); len(valid) > 0 {
+		placeholder = valid[0]
+	}
+
+	return checkArgs(cmd, slices.Repeat([]string{placeholder}, n))
+}
+
+// onlyValidArgs reports whether cmd.Args rejects arguments missing from ValidArgs,
+// as cobra.OnlyValidArgs does. Otherwise ValidArgs are only completion hints.
+// cmd.Args is probed with the smallest accepted count of valid arguments,
+// with the last one replaced by a placeholder that is not valid.
+func onlyValidArgs(cmd *cobra.Command) bool {
+	valid := validArgs(cmd)
+	if cmd.Args == nil || len(valid) == 0 {
+		return false
+	}
+
+	for n := 1; n <= argsProbeLimit; n++ {
+		args := slices.Repeat([]string{valid[0]}, n)
+		if !checkArgs(cmd, args) {
+			continue
+		}
+
+		args[n-1] = invalidArgProbe
+		return !checkArgs(cmd, args)
+	}
+
+	return false
+}
+
+// checkArgs reports whether cmd.Args accepts args. A validator that panics rejects them.
+func checkArgs(cmd *cobra.Command, args []string) (accepted bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			accepted = false
+		}
+	}()
+
+	return cmd.Args(cmd, args) == nil
+}
+
+// validArgs returns cmd.ValidArgs without their completion descriptions.
+func validArgs(cmd *cobra.Command) []string {
+	values := make([]string, 0, len(cmd.ValidArgs))
+	for _, v := range cmd.ValidArgs {
+		values = append(values, strings.SplitN(v, "\t", 2)[0])
+	}
+
+	return values
+}
+
+// annotationInt reads a non-negative integer annotation from cmd.Annotations.
+func annotationInt(cmd *cobra.Command, key string) (int, bool) {
+	v, ok := cmd.Annotations[key]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		slog.Warn("invalid non-negative integer value for annotation, skipping", "key", key, "value", v)
+		return 0, false
+	}
+
+	return n, true
+}
@@ -0,0 +1,172 @@
+package ophis
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestArgsRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        cobra.PositionalArgs
+		validArgs   []string
+		annotations map[string]string
+		expectedMin int
+		expectedMax *int
+		expectedOK  bool
+	}{
+		{
+			name:       "no validator",
+			expectedOK: false,
+		},
+		{
+			name:       "arbitrary args",
+			args:       cobra.ArbitraryArgs,
+			expectedOK: true,
+		},
+		{
+			name:        "no args",
+			args:        cobra.NoArgs,
+			expectedMax: intPtr(0),
+			expectedOK:  true,
+		},
+		{
+			name:        "exact args",
+			args:        cobra.ExactArgs(2),
+			expectedMin: 2,
+			expectedMax: intPtr(2),
+			expectedOK:  true,
+		},
+		{
+			name:        "minimum args",
+			args:        cobra.MinimumNArgs(1),
+			expectedMin: 1,
+			expectedOK:  true,
+		},
+		{
+			name:        "maximum args",
+			args:        cobra.MaximumNArgs(3),
+			expectedMax: intPtr(3),
+			expectedOK:  true,
+		},
+		{
+			name:        "range args",
+			args:        cobra.RangeArgs(1, 4),
+			expectedMin: 1,
+			expectedMax: intPtr(4),
+			expectedOK:  true,
+		},
+		{
+			name:        "exact valid args",
+			args:        cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+			validArgs:   []string{"pods\tList pods", "services"},
+			expectedMin: 1,
+			expectedMax: intPtr(1),
+			expectedOK:  true,
+		},
+		{
+			name:       "validator that rejects placeholders",
+			args:       func(_ *cobra.Command, _ []string) error { return errors.New("never") },
+			expectedOK: false,
+		},
+		{
+			name:       "validator that panics",
+			args:       func(_ *cobra.Command, args []string) error { _ = args[5]; return errors.New("too few") },
+			expectedOK: false,
+		},
+		{
+			name:        "annotations override the validator",
+			args:        cobra.ArbitraryArgs,
+			annotations: map[string]string{AnnotationArgsMin: "1", AnnotationArgsMax: "2"},
+			expectedMin: 1,
+			expectedMax: intPtr(2),
+			expectedOK:  true,
+		},
+		{
+			name:        "invalid annotation is ignored",
+			args:        cobra.ExactArgs(1),
+			annotations: map[string]string{AnnotationArgsMin: "-1"},
+			expectedMin: 1,
+			expectedMax: intPtr(1),
+			expectedOK:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "test", Args: tt.args, ValidArgs: tt.validArgs, Annotations: tt.annotations}
+			minArgs, maxArgs, ok := argsRange(cmd)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedMin, minArgs)
+			assert.Equal(t, tt.expectedMax, maxArgs)
+		})
+	}
+}
+
+func TestEnhanceArgsSchema(t *testing.T) {
+	cmd := &cobra.Command{
+		Use:       "get <resource>",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"pods\tList pods", "services"},
+		Run:       func(_ *cobra.Command, _ []string) {},
+	}
+
+	tool := Selector{}.createToolFromCmd(cmd, "root")
+	schema := tool.InputSchema.(*jsonschema.Schema)
+	args := schema.Properties["args"]
+
+	require.NotNil(t, args.MinItems)
+	require.NotNil(t, args.MaxItems)
+	assert.Equal(t, 1, *args.MinItems)
+	assert.Equal(t, 1, *args.MaxItems)
+	assert.Equal(t, []any{"pods", "services"}, args.Items.Enum)
+	assert.Contains(t, args.Description, "Usage pattern: <resource>")
+	assert.Contains(t, schema.Required, "args")
+}
+
+func TestEnhanceArgsSchemaHintOnlyValidArgs(t *testing.T) {
+	cmd := &cobra.Command{
+		Use:       "get <resource> [name]",
+		Args:      cobra.RangeArgs(1, 2),
+		ValidArgs: []string{"pods", "svc"},
+		Run:       func(_ *cobra.Command, _ []string) {},
+	}
+
+	tool := Selector{}.createToolFromCmd(cmd, "root")
+	schema := tool.InputSchema.(*jsonschema.Schema)
+
+	// ValidArgs only complete arguments, so names outside them are accepted
+	assert.Nil(t, schema.Properties["args"].Items.Enum)
+	validator, err := newInputValidator(schema)
+	require.NoError(t, err)
+	assert.Empty(t, validator.validate(json.RawMessage(`{"flags": {}, "args": ["pods", "web-1"]}`)))
+}
+
+func TestOnlyValidArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     cobra.PositionalArgs
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"only valid args", cobra.OnlyValidArgs, true},
+		{"combined with a count", cobra.MatchAll(cobra.RangeArgs(2, 3), cobra.OnlyValidArgs), true},
+		{"count only", cobra.RangeArgs(1, 2), false},
+		{"arbitrary", cobra.ArbitraryArgs, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "get", Args: tt.args, ValidArgs: []string{"pods\tList pods", "svc"}}
+			assert.Equal(t, tt.expected, onlyValidArgs(cmd))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package ophis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+// charsPerToken is the rough number of characters per token used for estimates.
+const charsPerToken = 4
+
+// fitToolDescription shortens desc to at most limit runes. A limit of zero or less disables it.
+//
+// When rebuild is set, desc is a default or enriched description, and shorter descriptions
+// are tried in turn before truncating: the usual description with only the first example
+// block, then Short with the first example block, then Short alone.
+// Otherwise desc was written by a template or hook, and is only truncated.
+func fitToolDescription(desc string, cmd *cobra.Command, limit int, rebuild bool) string {
+	if limit <= 0 || utf8.RuneCountInString(desc) <= limit {
+		return desc
+	}
+
+	if rebuild {
+		candidates := compactDescriptions(cmd)
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) <= limit {
+				return candidate
+			}
+		}
+
+		desc = candidates[len(candidates)-1]
+	}
+
+	return truncateText(limit, desc)
+}
+
+// compactDescriptions returns descriptions of cmd from longest to shortest.
+func compactDescriptions(cmd *cobra.Command) []string {
+	main := cmd.Long
+	if main == "" {
+		main = cmd.Short
+	}
+
+	if main == "" {
+		main = fmt.Sprintf("Execute the %s command", cmd.Name())
+	}
+
+	short := main
+	if cmd.Short != "" {
+		short = cmd.Short
+	}
+
+	var candidates []string
+	if example := firstExampleBlock(cmd.Example); example != "" {
+		candidates = append(candidates,
+			fmt.Sprintf("%s\nExamples:\n%s", main, example),
+			fmt.Sprintf("%s\nExamples:\n%s", short, example),
+		)
+	}
+
+	return append(candidates, main, short)
+}
+
+// firstExampleBlock returns the first block of example, where blocks are separated by blank lines.
+func firstExampleBlock(example string) string {
+	lines := strings.Split(strings.Trim(example, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			return strings.Join(lines[:i], "\n")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// fitFlagDescriptions shortens the description of every flag in the flags schema
+// to at most limit runes. A limit of zero or less disables it.
+func fitFlagDescriptions(flagsSchema *jsonschema.Schema, limit int) {
+	if limit <= 0 || flagsSchema == nil {
+		return
+	}
+
+	for _, flagSchema := range flagsSchema.Properties {
+		flagSchema.Description = truncateText(limit, flagSchema.Description)
+	}
+}
+
+// estimateTokens roughly estimates the number of tokens tool costs in a client's context,
+// from the size of its JSON definition.
+func estimateTokens(tool *mcp.Tool) int {
+	data, err := json.Marshal(tool)
+	if err != nil {
+		return 0
+	}
+
+	return (utf8.RuneCount(data) + charsPerToken - 1) / charsPerToken
+}
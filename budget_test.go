@@ -0,0 +1,93 @@
+package ophis
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFitToolDescription(t *testing.T) {
+	cmd := &cobra.Command{
+		Use:     "deploy",
+		Short:   "Deploy an app",
+		Long:    "Deploy an application to the cluster, creating or updating its resources.",
+		Example: "deploy web\n\ndeploy web --wait\n\ndeploy web --dry-run",
+	}
+	full := toolDescription(cmd)
+
+	tests := []struct {
+		name     string
+		limit    int
+		rebuild  bool
+		expected string
+	}{
+		{"unlimited", 0, true, full},
+		{"within budget", len(full), true, full},
+		{"first example only", 110, true, cmd.Long + "\nExamples:\ndeploy web"},
+		{"short with example", 40, true, "Deploy an app\nExamples:\ndeploy web"},
+		{"short only", 20, true, "Deploy an app"},
+		{"short truncated", 10, true, "Deploy ..."},
+		{"custom description truncated", 20, false, "Deploy an applica..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desc := fitToolDescription(full, cmd, tt.limit, tt.rebuild)
+			assert.Equal(t, tt.expected, desc)
+			if tt.limit > 0 {
+				assert.LessOrEqual(t, utf8.RuneCountInString(desc), tt.limit)
+			}
+		})
+	}
+}
+
+func TestFirstExampleBlock(t *testing.T) {
+	assert.Equal(t, "a\nb", firstExampleBlock("\na\nb\n  \nc"))
+	assert.Equal(t, "a", firstExampleBlock("a"))
+	assert.Equal(t, "", firstExampleBlock(""))
+}
+
+func TestDescriptionBudgets(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync files",
+		Long:  strings.Repeat("Synchronize files between two directories. ", 10),
+		Run:   func(_ *cobra.Command, _ []string) {},
+	}
+	cmd.Flags().String("exclude", "", strings.Repeat("Pattern of files to skip. ", 10))
+	root.AddCommand(cmd)
+
+	c := &Config{MaxDescriptionLength: 50, MaxFlagDescriptionLength: 30}
+	c.registerTools(root)
+
+	require.Len(t, c.tools, 1)
+	assert.Equal(t, "Sync files", c.tools[0].Description)
+	flags := c.tools[0].InputSchema.(*jsonschema.Schema).Properties["flags"]
+	assert.Equal(t, 30, utf8.RuneCountInString(flags.Properties["exclude"].Description))
+}
+
+func TestEstimateTokens(t *testing.T) {
+	small := &mcp.Tool{Name: "a", InputSchema: &jsonschema.Schema{Type: "object"}}
+	large := &mcp.Tool{Name: "b", Description: strings.Repeat("x", 400), InputSchema: &jsonschema.Schema{Type: "object"}}
+	assert.Positive(t, estimateTokens(small))
+	assert.InDelta(t, 100, estimateTokens(large)-estimateTokens(small), 10)
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	printTokenEstimates(cmd, []*mcp.Tool{small, large})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], "total")
+	assert.True(t, strings.HasSuffix(lines[1], " b"), "largest tool first")
+	assert.True(t, strings.HasSuffix(lines[2], " a"))
+}
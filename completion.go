@@ -0,0 +1,85 @@
+package ophis
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/cobra"
+)
+
+// addCompletionEnums turns static flag completions into enums on the flag schemas of cmd.
+// Only string and string array flags are changed.
+func addCompletionEnums(schema *jsonschema.Schema, cmd *cobra.Command) {
+	for name, flagSchema := range schema.Properties {
+		target := flagSchema
+		if flagSchema.Type == "array" && flagSchema.Items != nil {
+			target = flagSchema.Items
+		}
+
+		if target.Type != "string" {
+			continue
+		}
+
+		values, descriptions, ok := staticFlagCompletions(cmd, name)
+		if !ok {
+			continue
+		}
+
+		target.Enum = make([]any, 0, len(values))
+		for _, v := range values {
+			target.Enum = append(target.Enum, v)
+		}
+
+		if len(descriptions) > 0 {
+			flagSchema.Description += "\nValues:\n" + strings.Join(descriptions, "\n")
+		}
+
+		slog.Debug("added flag enum from completion", "command", cmd.CommandPath(), "flag", name, "values", values)
+	}
+}
+
+// staticFlagCompletions calls the completion function registered for a flag with empty input.
+// It reports ok only for a non-empty, static list: the directive must disable file completion
+// and must not signal an error or request file filtering.
+// descriptions holds a "- value: description" line for each completion that has a description.
+func staticFlagCompletions(cmd *cobra.Command, name string) (values, descriptions []string, ok bool) {
+	fn, exists := cmd.GetFlagCompletionFunc(name)
+	if !exists {
+		return nil, nil, false
+	}
+
+	completions, directive, ok := callCompletion(fn, cmd, []string{}, "")
+	if !ok || len(completions) == 0 {
+		return nil, nil, false
+	}
+
+	if directive&cobra.ShellCompDirectiveNoFileComp == 0 ||
+		directive&(cobra.ShellCompDirectiveError|cobra.ShellCompDirectiveFilterFileExt|cobra.ShellCompDirectiveFilterDirs) != 0 {
+		return nil, nil, false
+	}
+
+	for _, completion := range completions {
+		value, description, _ := strings.Cut(completion, "\t")
+		values = append(values, value)
+		if description != "" {
+			descriptions = append(descriptions, fmt.Sprintf("- %s: %s", value, description))
+		}
+	}
+
+	return values, descriptions, true
+}
+
+// callCompletion calls a cobra completion function, recovering from panics.
+func callCompletion(fn cobra.CompletionFunc, cmd *cobra.Command, args []string, toComplete string) (completions []cobra.Completion, directive cobra.ShellCompDirective, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Warn("completion function panicked", "command", cmd.CommandPath(), "panic", r)
+			ok = false
+		}
+	}()
+
+	completions, directive = fn(cmd, args, toComplete)
+	return completions, directive, true
+}
@@ -0,0 +1,90 @@
+package ophis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddCompletionEnums(t *testing.T) {
+	cmd := &cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}}
+	cmd.Flags().String("output", "table", "Output format")
+	cmd.Flags().String("namespace", "", "Namespace")
+	cmd.Flags().String("file", "", "Input file")
+	cmd.Flags().StringSlice("columns", nil, "Columns to show")
+	cmd.Flags().Int("limit", 0, "Limit results")
+	cmd.Flags().String("panics", "", "Broken completion")
+	cmd.Flags().String("plain", "", "No completion")
+
+	register := func(name string, fn cobra.CompletionFunc) {
+		require.NoError(t, cmd.RegisterFlagCompletionFunc(name, fn))
+	}
+	register("output", cobra.FixedCompletions([]string{"json\tJSON output", "yaml", "table\tHuman readable"}, cobra.ShellCompDirectiveNoFileComp))
+	register("namespace", cobra.FixedCompletions([]string{"default"}, cobra.ShellCompDirectiveDefault))
+	register("file", cobra.FixedCompletions([]string{"yaml"}, cobra.ShellCompDirectiveNoFileComp|cobra.ShellCompDirectiveFilterFileExt))
+	register("columns", cobra.FixedCompletions([]string{"name", "age"}, cobra.ShellCompDirectiveNoFileComp))
+	register("limit", cobra.FixedCompletions([]string{"10", "100"}, cobra.ShellCompDirectiveNoFileComp))
+	register("panics", func(_ *cobra.Command, _ []string, _ string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		panic("boom")
+	})
+
+	tool := Selector{}.createToolFromCmd(cmd, "root")
+	flags := tool.InputSchema.(*jsonschema.Schema).Properties["flags"]
+	addCompletionEnums(flags, cmd)
+
+	// Static completions become enums, with descriptions appended
+	assert.Equal(t, []any{"json", "yaml", "table"}, flags.Properties["output"].Enum)
+	assert.Contains(t, flags.Properties["output"].Description, "- json: JSON output")
+	assert.Contains(t, flags.Properties["output"].Description, "- table: Human readable")
+	assert.NotContains(t, flags.Properties["output"].Description, "- yaml")
+
+	// String arrays get enum items
+	assert.Equal(t, []any{"name", "age"}, flags.Properties["columns"].Items.Enum)
+	assert.Equal(t, "Columns to show", flags.Properties["columns"].Description)
+
+	// Everything else is left alone
+	for _, name := range []string{"namespace", "file", "limit", "panics", "plain"} {
+		assert.Nil(t, flags.Properties[name].Enum, name)
+	}
+}
+
+func TestCompletionCapability(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	root.AddCommand(&cobra.Command{Use: "run", Run: func(_ *cobra.Command, _ []string) {}})
+
+	capabilities := func(t *testing.T, c *Config) *mcp.ServerCapabilities {
+		t.Helper()
+		require.NoError(t, c.registerTools(root))
+
+		ctx := context.Background()
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		serverSession, err := c.server.Connect(ctx, serverTransport, nil)
+		require.NoError(t, err)
+		defer func() { _ = serverSession.Close() }()
+
+		client := mcp.NewClient(&mcp.Implementation{Name: "client"}, nil)
+		session, err := client.Connect(ctx, clientTransport, nil)
+		require.NoError(t, err)
+		defer func() { _ = session.Close() }()
+
+		return session.InitializeResult().Capabilities
+	}
+
+	t.Run("not advertised by default", func(t *testing.T) {
+		assert.Nil(t, capabilities(t, &Config{}).Completions)
+	})
+
+	t.Run("caller's handler", func(t *testing.T) {
+		c := &Config{ServerOptions: &mcp.ServerOptions{
+			CompletionHandler: func(context.Context, *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+				return &mcp.CompleteResult{}, nil
+			},
+		}}
+		assert.NotNil(t, capabilities(t, c).Completions)
+	})
+}
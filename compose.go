@@ -0,0 +1,172 @@
+package ophis
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Selectors are plain functions, which can neither hold a description nor be compared.
+// Every selector built by this package is a closure made by describedCmd or describedFlag,
+// so Describe recognizes one by the code of that closure, and asks it for its description
+// by calling it with a probe value while holding describeMu.
+var (
+	describeMu        sync.Mutex
+	describeResult    string // set by the probed selector
+	cmdProbe          = &cobra.Command{}
+	flagProbe         = &pflag.Flag{}
+	describedCmdCode  = reflect.ValueOf(describedCmd(nil, "")).Pointer()
+	describedFlagCode = reflect.ValueOf(describedFlag(nil, "")).Pointer()
+	noFlagsCode       = reflect.ValueOf(NoFlags).Pointer()
+)
+
+// And creates a selector that accepts what every one of selectors accepts.
+// A nil selector accepts everything, as it does in a Selector, and And() accepts everything.
+// Example: And(AllowCmdsMatching("kubectl **"), Not(AllowCmdsContaining("secret"))).
+func And[S ~func(T) bool, T any](selectors ...S) S {
+	return described(S(func(v T) bool {
+		for _, selector := range selectors {
+			if selector != nil && !selector(v) {
+				return false
+			}
+		}
+
+		return true
+	}), "And(%s)", describeAll(selectors))
+}
+
+// Or creates a selector that accepts what any one of selectors accepts.
+// A nil selector accepts everything, as it does in a Selector, and Or() accepts nothing.
+// Example: Or(AllowFlags("namespace"), AllowFlagsMatching("output*")).
+func Or[S ~func(T) bool, T any](selectors ...S) S {
+	return described(S(func(v T) bool {
+		for _, selector := range selectors {
+			if selector == nil || selector(v) {
+				return true
+			}
+		}
+
+		return false
+	}), "Or(%s)", describeAll(selectors))
+}
+
+// Not creates a selector that accepts what selector rejects.
+// A nil selector accepts everything, so Not(nil) accepts nothing.
+// Example: Not(AllowCmdsMatching("* delete **")).
+func Not[S ~func(T) bool, T any](selector S) S {
+	return described(S(func(v T) bool {
+		return selector != nil && !selector(v)
+	}), "Not(%s)", Describe(selector))
+}
+
+// Describe returns a readable description of a selector, such as
+// `And(AllowCmdsMatching("kubectl get *"), Not(AllowCmds("kubectl get secrets")))`.
+// Selectors built by this package describe themselves; a nil selector is "all",
+// and any other selector is "custom".
+func Describe[S ~func(T) bool, T any](selector S) string {
+	if selector == nil {
+		return "all"
+	}
+
+	// Only closures made by describedCmd and describedFlag are called, so
+	// describing a custom selector never runs it
+	switch f := any((func(T) bool)(selector)).(type) {
+	case func(*cobra.Command) bool:
+		if reflect.ValueOf(f).Pointer() == describedCmdCode {
+			return probeDescription(f, cmdProbe)
+		}
+	case func(*pflag.Flag) bool:
+		switch reflect.ValueOf(f).Pointer() {
+		case describedFlagCode:
+			return probeDescription(f, flagProbe)
+		case noFlagsCode:
+			return "NoFlags"
+		}
+	}
+
+	return "custom"
+}
+
+// String describes the command and flag selectors of s, for debug logs.
+func (s Selector) String() string {
+	return fmt.Sprintf("cmds=%s local_flags=%s inherited_flags=%s",
+		Describe(s.CmdSelector), Describe(s.LocalFlagSelector), Describe(s.InheritedFlagSelector))
+}
+
+// described returns a selector that accepts what match accepts, described by format and args.
+// Selectors of values other than commands and flags are returned without a description.
+func described[S ~func(T) bool, T any](match S, format string, args ...any) S {
+	desc := fmt.Sprintf(format, args...)
+	switch m := any((func(T) bool)(match)).(type) {
+	case func(*cobra.Command) bool:
+		return S(any(describedCmd(m, desc)).(func(T) bool))
+	case func(*pflag.Flag) bool:
+		return S(any(describedFlag(m, desc)).(func(T) bool))
+	default:
+		return match
+	}
+}
+
+// describedCmd returns a command selector that accepts what match accepts, and reports
+// desc when called with cmdProbe. It must not be inlined, since inlining would copy the
+// closure, which Describe recognizes by its code.
+//
+//go:noinline
+func describedCmd(match func(*cobra.Command) bool, desc string) func(*cobra.Command) bool {
+	return func(cmd *cobra.Command) bool {
+		if cmd == cmdProbe {
+			describeResult = desc
+			return false
+		}
+
+		return match(cmd)
+	}
+}
+
+// describedFlag is the flag selector counterpart of describedCmd.
+//
+//go:noinline
+func describedFlag(match func(*pflag.Flag) bool, desc string) func(*pflag.Flag) bool {
+	return func(flag *pflag.Flag) bool {
+		if flag == flagProbe {
+			describeResult = desc
+			return false
+		}
+
+		return match(flag)
+	}
+}
+
+// probeDescription calls a selector built by this package with probe and returns the
+// description it reports.
+func probeDescription[T any](selector func(T) bool, probe T) string {
+	describeMu.Lock()
+	defer describeMu.Unlock()
+
+	selector(probe)
+	return describeResult
+}
+
+// describeAll joins the descriptions of selectors with commas.
+func describeAll[S ~func(T) bool, T any](selectors []S) string {
+	descs := make([]string, 0, len(selectors))
+	for _, selector := range selectors {
+		descs = append(descs, Describe(selector))
+	}
+
+	return strings.Join(descs, ", ")
+}
+
+// quoteAll quotes each of values and joins them with commas.
+func quoteAll(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", v))
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,219 @@
+package ophis
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdCombinators(t *testing.T) {
+	tests := []struct {
+		name         string
+		selector     CmdSelector
+		commandNames []string
+		expected     bool
+	}{
+		{
+			name:         "and accepts when all accept",
+			selector:     And(AllowCmdsMatching("kubectl **"), ExcludeCmdsContaining("secret")),
+			commandNames: []string{"kubectl", "get", "pods"},
+			expected:     true,
+		},
+		{
+			name:         "and rejects when one rejects",
+			selector:     And(AllowCmdsMatching("kubectl **"), ExcludeCmdsContaining("secret")),
+			commandNames: []string{"kubectl", "get", "secrets"},
+			expected:     false,
+		},
+		{
+			name:         "and treats nil as accepting",
+			selector:     And(nil, AllowCmds("kubectl get")),
+			commandNames: []string{"kubectl", "get"},
+			expected:     true,
+		},
+		{
+			name:         "empty and accepts",
+			selector:     And[CmdSelector](),
+			commandNames: []string{"kubectl", "get"},
+			expected:     true,
+		},
+		{
+			name:         "or accepts when one accepts",
+			selector:     Or(AllowCmds("helm list"), AllowCmdsContaining("get")),
+			commandNames: []string{"kubectl", "get", "pods"},
+			expected:     true,
+		},
+		{
+			name:         "or rejects when none accept",
+			selector:     Or(AllowCmds("helm list"), AllowCmdsContaining("get")),
+			commandNames: []string{"kubectl", "delete"},
+			expected:     false,
+		},
+		{
+			name:         "empty or rejects",
+			selector:     Or[CmdSelector](),
+			commandNames: []string{"kubectl", "get"},
+			expected:     false,
+		},
+		{
+			name:         "not inverts",
+			selector:     Not(AllowCmdsMatching("* delete **")),
+			commandNames: []string{"kubectl", "delete", "pod"},
+			expected:     false,
+		},
+		{
+			name:         "not of nil rejects",
+			selector:     Not[CmdSelector](nil),
+			commandNames: []string{"kubectl", "get"},
+			expected:     false,
+		},
+		{
+			name: "nested",
+			selector: Or(
+				AllowCmds("helm list"),
+				And(AllowCmdsMatching("kubectl get *"), Not(AllowCmds("kubectl get secrets"))),
+			),
+			commandNames: []string{"kubectl", "get", "pods"},
+			expected:     true,
+		},
+		{
+			name: "custom selector",
+			selector: And(AllowCmdsContaining("kubectl"), func(cmd *cobra.Command) bool {
+				return cmd.Name() != "exec"
+			}),
+			commandNames: []string{"kubectl", "exec"},
+			expected:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := buildCommandTree(tt.commandNames...)
+			assert.Equal(t, tt.expected, tt.selector(cmd))
+		})
+	}
+}
+
+func TestFlagCombinators(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector FlagSelector
+		flagName string
+		expected bool
+	}{
+		{
+			name:     "and accepts when all accept",
+			selector: And(AllowFlagsMatching("*-timeout"), ExcludeFlags("idle-timeout")),
+			flagName: "request-timeout",
+			expected: true,
+		},
+		{
+			name:     "and rejects when one rejects",
+			selector: And(AllowFlagsMatching("*-timeout"), ExcludeFlags("idle-timeout")),
+			flagName: "idle-timeout",
+			expected: false,
+		},
+		{
+			name:     "or accepts when one accepts",
+			selector: Or(AllowFlags("namespace"), AllowFlagsMatching("output*")),
+			flagName: "output-format",
+			expected: true,
+		},
+		{
+			name:     "or rejects when none accept",
+			selector: Or(AllowFlags("namespace"), AllowFlagsMatching("output*")),
+			flagName: "token",
+			expected: false,
+		},
+		{
+			name:     "not inverts",
+			selector: Not(FlagSelector(NoFlags)),
+			flagName: "namespace",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.selector(&pflag.Flag{Name: tt.flagName}))
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	t.Run("command selectors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			selector CmdSelector
+			expected string
+		}{
+			{
+				name:     "nil",
+				selector: nil,
+				expected: "all",
+			},
+			{
+				name:     "custom",
+				selector: func(_ *cobra.Command) bool { return true },
+				expected: "custom",
+			},
+			{
+				name:     "constructor",
+				selector: AllowCmds("kubectl get", "helm list"),
+				expected: `AllowCmds("kubectl get", "helm list")`,
+			},
+			{
+				name:     "regexp",
+				selector: ExcludeCmdsMatchingRegexp(`\S+ delete`),
+				expected: `ExcludeCmdsMatchingRegexp("\\S+ delete")`,
+			},
+			{
+				name: "composed",
+				selector: Or(
+					And(AllowCmdsMatching("kubectl get *"), Not(AllowCmds("kubectl get secrets"))),
+					func(_ *cobra.Command) bool { return false },
+					nil,
+				),
+				expected: `Or(And(AllowCmdsMatching("kubectl get *"), Not(AllowCmds("kubectl get secrets"))), custom, all)`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, Describe(tt.selector))
+			})
+		}
+	})
+
+	t.Run("flag selectors", func(t *testing.T) {
+		assert.Equal(t, "NoFlags", Describe(FlagSelector(NoFlags)))
+		assert.Equal(t, `Not(ExcludeFlagsMatching("*token*"))`, Describe(Not(ExcludeFlagsMatching("*token*"))))
+	})
+
+	t.Run("closures from the same constructor", func(t *testing.T) {
+		a := AllowCmdsContaining("get")
+		b := AllowCmdsContaining("list")
+		assert.Equal(t, `AllowCmdsContaining("get")`, Describe(a))
+		assert.Equal(t, `AllowCmdsContaining("list")`, Describe(b))
+	})
+
+	t.Run("custom selectors are not called", func(t *testing.T) {
+		called := false
+		custom := CmdSelector(func(_ *cobra.Command) bool {
+			called = true
+			return true
+		})
+		assert.Equal(t, "And(custom)", Describe(And(custom)))
+		assert.False(t, called)
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		s := Selector{
+			CmdSelector:           AllowCmdsMatching("kubectl **"),
+			InheritedFlagSelector: NoFlags,
+		}
+		assert.Equal(t, `cmds=AllowCmdsMatching("kubectl **") local_flags=all inherited_flags=NoFlags`, s.String())
+	})
+}
@@ -1,18 +1,70 @@
 package ophis
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
+// ExecutionMode selects how tool calls run the underlying command.
+type ExecutionMode int
+
+const (
+	// ExecutionModeSubprocess re-executes the CLI binary for every tool call.
+	// Each call gets a fresh process, so no state leaks between calls.
+	ExecutionModeSubprocess ExecutionMode = iota
+
+	// ExecutionModeInProcess runs the matched command inside the MCP server process.
+	// This avoids a process spawn per call and keeps warm caches (clients, auth tokens)
+	// alive, at the cost of sharing process state between calls:
+	//   - Calls are serialized, and flags are reset to their defaults before each call
+	//   - Commands must write to cmd.OutOrStdout()/cmd.ErrOrStderr(); writes to
+	//     os.Stdout are not captured and may corrupt the stdio transport
+	//   - RunE errors become exit code 1, or the code returned by an ExitCode() int method
+	//   - Context cancellation is cooperative; the command must honor cmd.Context()
+	ExecutionModeInProcess
+)
+
+// String returns the name of the execution mode.
+func (m ExecutionMode) String() string {
+	switch m {
+	case ExecutionModeInProcess:
+		return "in-process"
+	default:
+		return "subprocess"
+	}
+}
+
+// ErrorPolicy decides whether a tool call is reported to the client as an error.
+// When it returns true, the result is marked with isError and carries a text summary
+// of the failure; the structured ToolOutput is returned alongside it either way.
+type ErrorPolicy func(ToolOutput) bool
+
+// ErrorOnNonZeroExit is an ErrorPolicy that reports an error when the command
+// exits with a non-zero code or times out. This is the default policy.
+func ErrorOnNonZeroExit(output ToolOutput) bool {
+	return output.ExitCode != 0 || output.TimedOut
+}
+
+// NeverError is an ErrorPolicy that always reports success.
+// Clients must inspect exitCode to detect failed commands.
+func NeverError(_ ToolOutput) bool {
+	return false
+}
+
 // Config customizes MCP server behavior and command-to-tool conversion.
 type Config struct {
 	// CommandName is the Use name for the top-level command returned by Command().
@@ -37,6 +89,16 @@ type Config struct {
 	// If nil or empty, defaults to exposing all commands with all flags.
 	Selectors []Selector
 
+	// SelectorsFile is the path of a YAML or JSON file of selectors (see LoadConfigFile)
+	// that narrows Selectors: a command is only exposed if both a selector in Selectors
+	// and a selector in the file match it, and a flag only if both accept it.
+	// This lets operators restrict the tools without recompiling, but never widen them.
+	// A timeout in the file shortens the timeout of the tools it matches, including
+	// one set by the AnnotationTimeout command annotation, but never lengthens it.
+	// The start and stream commands set it from their --selectors-file flag.
+	// Default: "" (no file).
+	SelectorsFile string
+
 	// DefaultEnv specifies environment variables that are automatically
 	// included when `enable` writes a server config for any editor.
 	// These are merged with user-provided --env values; user values
@@ -62,6 +124,62 @@ type Config struct {
 	// If empty, the root command name is used as-is.
 	ToolNamePrefix string
 
+	// ExecutionMode selects how tool calls run the underlying command.
+	// Default: ExecutionModeSubprocess.
+	ExecutionMode ExecutionMode
+
+	// Timeout limits how long a single tool call may run.
+	// It can be overridden per selector (Selector.Timeout) and per command
+	// (the AnnotationTimeout command annotation).
+	// When it fires, the subprocess receives SIGTERM, followed by SIGKILL after a
+	// grace period, and the ToolOutput is marked as timed out.
+	// Default: 0 (no timeout).
+	Timeout time.Duration
+
+	// MaxOutputBytes caps the size of stdout and stderr returned for a tool call, per stream.
+	// Output beyond the cap is dropped from the middle: the first and last halves are kept,
+	// separated by a truncation marker, and the ToolOutput is marked as truncated.
+	// Can be overridden per selector (Selector.MaxOutputBytes).
+	// Default: 0 (unlimited).
+	MaxOutputBytes int
+
+	// MaxOutputLines caps the number of lines of stdout and stderr returned for a tool call,
+	// per stream, in the same way as MaxOutputBytes. Both caps apply when both are set.
+	// Lines are kept whole, so set MaxOutputBytes as well to bound the size of the output.
+	// Can be overridden per selector (Selector.MaxOutputLines).
+	// Default: 0 (unlimited).
+	MaxOutputLines int
+
+	// FlagCompletionEnums turns flag completion functions into JSON schema enums.
+	// When enabled, the completion function registered for each exposed flag
+	// (cmd.RegisterFlagCompletionFunc) is called once at registration with empty input.
+	// If it returns a non-empty static list with ShellCompDirectiveNoFileComp, the values
+	// become an enum on the flag's schema, and any "value\tdescription" descriptions are
+	// appended to the flag description.
+	// Completion functions that contact remote services are called too, so only enable
+	// this for CLIs whose completions are cheap.
+	// Default: false.
+	FlagCompletionEnums bool
+
+	// MaxDescriptionLength caps the length of each tool description, in characters.
+	// Descriptions over the cap are rebuilt from less text: first only the first block
+	// of the command's Example is kept, then Short is used instead of Long, and finally
+	// the text is truncated. Descriptions from Selector.DescriptionTemplate or
+	// Selector.DescriptionFunc are only truncated.
+	// Run the tools command to see the estimated token cost of each tool.
+	// Default: 0 (unlimited).
+	MaxDescriptionLength int
+
+	// MaxFlagDescriptionLength caps the length of each flag description in the input
+	// schemas, in characters. Longer descriptions are truncated.
+	// Default: 0 (unlimited).
+	MaxFlagDescriptionLength int
+
+	// ErrorPolicy decides whether a finished tool call is reported as an MCP tool error.
+	// Use ErrorOnNonZeroExit, NeverError, or a custom func.
+	// Default: ErrorOnNonZeroExit.
+	ErrorPolicy ErrorPolicy
+
 	// SloggerOptions configures logging to stderr.
 	// Default: Info level logging.
 	SloggerOptions *slog.HandlerOptions
@@ -74,7 +192,19 @@ type Config struct {
 
 	server         *mcp.Server
 	tools          []*mcp.Tool
-	toolNamePrefix string // resolved prefix (either ToolNamePrefix or root command name)
+	toolNamePrefix string                // resolved prefix (either ToolNamePrefix or root command name)
+	toolEntries    map[string]*toolEntry // tool name -> settings resolved at registration
+	rootCmd        *cobra.Command        // root of the command tree, used for in-process execution
+	execSlot       chan struct{}         // holds a value while an in-process call runs
+	execSlotOnce   sync.Once             // creates execSlot
+}
+
+// errorPolicy returns the configured ErrorPolicy, defaulting to ErrorOnNonZeroExit.
+func (c *Config) errorPolicy() ErrorPolicy {
+	if c.ErrorPolicy != nil {
+		return c.ErrorPolicy
+	}
+	return ErrorOnNonZeroExit
 }
 
 // commandName returns the configured CommandName, defaulting to "mcp".
@@ -90,12 +220,25 @@ func (c *Config) serveStdio(cmd *cobra.Command) error {
 		c.Transport = &mcp.StdioTransport{}
 	}
 
-	c.registerTools(cmd)
+	if err := c.applySelectorsFile(); err != nil {
+		return err
+	}
+
+	if err := c.registerTools(cmd); err != nil {
+		return err
+	}
+
 	return c.server.Run(cmd.Context(), c.Transport)
 }
 
 func (c *Config) serveHTTP(cmd *cobra.Command, addr string) error {
-	c.registerTools(cmd)
+	if err := c.applySelectorsFile(); err != nil {
+		return err
+	}
+
+	if err := c.registerTools(cmd); err != nil {
+		return err
+	}
 
 	// Create the streamable HTTP handler.
 	handler := mcp.NewStreamableHTTPHandler(func(_ *http.Request) *mcp.Server {
@@ -125,8 +268,9 @@ func (c *Config) serveHTTP(cmd *cobra.Command, addr string) error {
 	return server.ListenAndServe()
 }
 
-// registerTools fully initializes a MCP server and populates c.tools
-func (c *Config) registerTools(cmd *cobra.Command) {
+// registerTools fully initializes a MCP server and populates c.tools.
+// It returns an error if tool names are invalid or collide; the other tools are still registered.
+func (c *Config) registerTools(cmd *cobra.Command) error {
 	// slog to stderr
 	handler := slog.NewTextHandler(os.Stderr, c.SloggerOptions)
 	slog.SetDefault(slog.New(handler))
@@ -137,6 +281,8 @@ func (c *Config) registerTools(cmd *cobra.Command) {
 		rootCmd = rootCmd.Parent()
 	}
 
+	c.rootCmd = rootCmd
+
 	// resolve tool name prefix
 	if c.ToolNamePrefix != "" {
 		c.toolNamePrefix = c.ToolNamePrefix
@@ -150,59 +296,201 @@ func (c *Config) registerTools(cmd *cobra.Command) {
 		Version: rootCmd.Version,
 	}, c.ServerOptions)
 
+	// report invalid arguments as tool errors before the SDK rejects them
+	c.server.AddReceivingMiddleware(c.validationMiddleware)
+
 	// ensure at least one selector exists for tool creation logic
 	if len(c.Selectors) == 0 {
 		c.Selectors = []Selector{{}}
 	}
 
 	// register tools
-	c.registerToolsRecursive(rootCmd)
+	c.toolEntries = make(map[string]*toolEntry)
+	return c.registerToolsRecursive(rootCmd)
 }
 
 // registerTools explores a cmd tree, making tools recursively out of the provided cmd and its children
-func (c *Config) registerToolsRecursive(cmd *cobra.Command) {
+func (c *Config) registerToolsRecursive(cmd *cobra.Command) error {
 	// register all subcommands
+	var errs []error
 	for _, subCmd := range cmd.Commands() {
-		c.registerToolsRecursive(subCmd)
+		errs = append(errs, c.registerToolsRecursive(subCmd))
 	}
 
 	// apply basic filters
 	if c.cmdFilter(cmd) {
-		return
+		return errors.Join(errs...)
 	}
 
-	// cycle through selectors until one matches the cmd
-	for i, s := range c.Selectors {
-		if s.CmdSelector != nil && !s.CmdSelector(cmd) {
-			continue
+	// use the first selector that matches the cmd
+	i, s, ok := c.matchSelector(cmd)
+	if !ok {
+		return errors.Join(errs...)
+	}
+
+	// create tool from cmd
+	tool := s.createToolFromCmd(cmd, c.toolNamePrefix)
+	if err := s.checkToolName(cmd, tool.Name, c.toolCmd); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	override := s.toolOverride(cmd)
+
+	flagsSchema := tool.InputSchema.(*jsonschema.Schema).Properties["flags"]
+	if c.FlagCompletionEnums {
+		addCompletionEnums(flagsSchema, cmd)
+	}
+
+	// keep descriptions within budget
+	rebuild := s.DescriptionFunc == nil && s.DescriptionTemplate == "" && override.Description == ""
+	tool.Description = fitToolDescription(tool.Description, cmd, c.MaxDescriptionLength, rebuild)
+	fitFlagDescriptions(flagsSchema, c.MaxFlagDescriptionLength)
+	slog.Debug("created tool", "tool_name", tool.Name, "selector_index", i, "selector", s)
+
+	result, err := checkOutputSchema(cmd, tool.OutputSchema.(*jsonschema.Schema))
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	validator, err := newInputValidator(tool.InputSchema.(*jsonschema.Schema))
+	if err != nil {
+		slog.Warn("failed to resolve input schema, skipping argument validation", "tool_name", tool.Name, "error", err)
+	}
+
+	// record the command path so execute can rebuild argv without parsing the tool name
+	c.toolEntries[tool.Name] = &toolEntry{
+		cmd:            cmd,
+		path:           commandPath(cmd),
+		timeout:        c.toolTimeout(s, cmd),
+		maxOutputBytes: cmp.Or(s.MaxOutputBytes, c.MaxOutputBytes),
+		maxOutputLines: cmp.Or(s.MaxOutputLines, c.MaxOutputLines),
+		progress:       !s.DisableProgress,
+		jsonOutput:     s.jsonOutput(cmd),
+		resultSchema:   result,
+		validator:      validator,
+	}
+
+	// register tool with server
+	mcp.AddTool(c.server, tool, s.handler(c.execute))
+
+	// add tool to manager's tool list (for `tools` command)
+	c.tools = append(c.tools, tool)
+	return errors.Join(errs...)
+}
+
+// toolCmd returns the command of the registered tool called name, if there is one.
+func (c *Config) toolCmd(name string) (*cobra.Command, bool) {
+	entry, ok := c.toolEntries[name]
+	if !ok {
+		return nil, false
+	}
+
+	return entry.cmd, true
+}
+
+// checkToolName reports an error if name, the name of the tool s creates from cmd, is already
+// the name of another command's tool as looked up by usedBy, since the server would silently
+// replace a tool registered under the same name. It also reports an error if an overridden
+// name is not a valid MCP tool name. A name derived from the command path is only warned
+// about, because commands such as "db:migrate" were always registered as they are.
+func (s Selector) checkToolName(cmd *cobra.Command, name string, usedBy func(name string) (*cobra.Command, bool)) error {
+	if err := validateToolName(name); err != nil {
+		if s.toolOverride(cmd).Name != "" {
+			return fmt.Errorf("invalid tool name override for command %q: %w", cmd.CommandPath(), err)
 		}
 
-		// create tool from cmd
-		tool := s.createToolFromCmd(cmd, c.toolNamePrefix)
-		slog.Debug("created tool", "tool_name", tool.Name, "selector_index", i)
+		slog.Warn("tool name is not a valid MCP tool name", "command", cmd.CommandPath(), "error", err)
+	}
+
+	if other, ok := usedBy(name); ok {
+		return fmt.Errorf("tool name %q of command %q is already used by command %q", name, cmd.CommandPath(), other.CommandPath())
+	}
+
+	return nil
+}
+
+// checkOutputSchema reports an error naming cmd if outputSchema, the output schema of its
+// tool, cannot be resolved, since the server panics on such a schema. It returns the
+// resolved schema of the result field, which is nil if the command does not declare one.
+func checkOutputSchema(cmd *cobra.Command, outputSchema *jsonschema.Schema) (*jsonschema.Resolved, error) {
+	result, err := resolvedResultSchema(cmd)
+	if err == nil {
+		_, err = outputSchema.Resolve(nil)
+	}
 
-		// register tool with server
-		mcp.AddTool(c.server, tool, s.execute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output schema for command %q: %w", cmd.CommandPath(), err)
+	}
 
-		// add tool to manager's tool list (for `tools` command)
-		c.tools = append(c.tools, tool)
+	return result, nil
+}
+
+// toolTimeout resolves the execution timeout for cmd.
+// The command's AnnotationTimeout wins over the selector's Timeout, which wins over the config's Timeout.
+// A timeout from the selectors file then shortens the result, but never lengthens it.
+func (c *Config) toolTimeout(s Selector, cmd *cobra.Command) time.Duration {
+	timeout := c.Timeout
+	if annotated, ok := annotationTimeout(cmd); ok {
+		timeout = annotated
+	} else if s.Timeout != 0 {
+		timeout = s.Timeout
+	}
 
-		// only the first matching selector is used
-		break
+	if s.narrowed != nil && s.narrowed.timeout > 0 && (timeout == 0 || s.narrowed.timeout < timeout) {
+		return s.narrowed.timeout
 	}
+
+	return timeout
+}
+
+// commandPath returns the names of cmd and its ancestors, excluding the root command.
+// For example, "kubectl get pods" becomes ["get", "pods"].
+func commandPath(cmd *cobra.Command) []string {
+	var path []string
+	for ; cmd.HasParent(); cmd = cmd.Parent() {
+		path = append([]string{cmd.Name()}, path...)
+	}
+
+	return path
 }
 
 // cmdFilter returns true if cmd should be filtered out.
 // It uses the configured CommandName (defaulting to "mcp") to exclude
 // the ophis command group from being exposed as MCP tools.
 func (c *Config) cmdFilter(cmd *cobra.Command) bool {
-	if cmd.Hidden || cmd.Deprecated != "" {
-		return true
+	return c.filterReason(cmd) != ""
+}
+
+// filterReason returns why cmd is filtered out, or "" if it passes the basic filters.
+func (c *Config) filterReason(cmd *cobra.Command) string {
+	switch {
+	case cmd.Hidden:
+		return "hidden"
+	case cmd.Deprecated != "":
+		return "deprecated"
+	case cmd.Run == nil && cmd.RunE == nil && cmd.PreRun == nil && cmd.PreRunE == nil:
+		return "not runnable"
+	case c.isBuiltinCmd(cmd):
+		return "built-in"
+	default:
+		return ""
 	}
+}
 
-	if cmd.Run == nil && cmd.RunE == nil && cmd.PreRun == nil && cmd.PreRunE == nil {
-		return true
+// isBuiltinCmd reports whether the path of cmd contains the name of the mcp, help or completion command.
+func (c *Config) isBuiltinCmd(cmd *cobra.Command) bool {
+	path := cmd.CommandPath()
+	return strings.Contains(path, c.commandName()) || strings.Contains(path, "help") || strings.Contains(path, "completion")
+}
+
+// matchSelector returns the index of the first selector that matches cmd, and the selector.
+// It reports false if no selector matches.
+func (c *Config) matchSelector(cmd *cobra.Command) (int, Selector, bool) {
+	for i, s := range c.Selectors {
+		if s.CmdSelector == nil || s.CmdSelector(cmd) {
+			return i, s, true
+		}
 	}
 
-	return AllowCmdsContaining(c.commandName(), "help", "completion")(cmd)
+	return 0, Selector{}, false
 }
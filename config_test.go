@@ -2,9 +2,11 @@ package ophis
 
 import (
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCmdFilter(t *testing.T) {
@@ -104,3 +106,133 @@ func TestCommandNameDefault(t *testing.T) {
 	var nilConfig *Config
 	assert.Equal(t, "mcp", nilConfig.commandName())
 }
+
+func TestRegisterToolsRecordsCommandPaths(t *testing.T) {
+	root := &cobra.Command{Use: "my_cli"}
+	get := &cobra.Command{Use: "get"}
+	all := &cobra.Command{Use: "get_all", Run: func(_ *cobra.Command, _ []string) {}}
+	get.AddCommand(all)
+	root.AddCommand(get)
+
+	c := &Config{}
+	c.registerTools(root)
+
+	require.Contains(t, c.toolEntries, "my_cli_get_get_all")
+	assert.Equal(t, []string{"get", "get_all"}, c.toolEntries["my_cli_get_get_all"].path)
+}
+
+func TestRegisterToolsNameCollisions(t *testing.T) {
+	newTree := func() (*cobra.Command, *cobra.Command, *cobra.Command) {
+		root := &cobra.Command{Use: "cli"}
+		list := &cobra.Command{Use: "list", Run: func(_ *cobra.Command, _ []string) {}}
+		get := &cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}}
+		root.AddCommand(list, get)
+		return root, list, get
+	}
+
+	t.Run("annotation collides with generated name", func(t *testing.T) {
+		root, list, _ := newTree()
+		list.Annotations = map[string]string{AnnotationName: "cli_get"}
+
+		c := &Config{}
+		err := c.registerTools(root)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `tool name "cli_get"`)
+		assert.Contains(t, err.Error(), `"cli list"`)
+		assert.Contains(t, err.Error(), `"cli get"`)
+		assert.Len(t, c.tools, 1, "the first command keeps the name")
+	})
+
+	t.Run("selector overrides collide", func(t *testing.T) {
+		root, _, _ := newTree()
+		c := &Config{
+			Selectors: []Selector{{
+				Overrides: map[string]ToolOverride{
+					"cli list": {Name: "read"},
+					"cli get":  {Name: "read"},
+				},
+			}},
+		}
+		err := c.registerTools(root)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `tool name "read"`)
+	})
+
+	t.Run("invalid override", func(t *testing.T) {
+		root, list, _ := newTree()
+		list.Annotations = map[string]string{AnnotationName: "list all"}
+
+		c := &Config{}
+		err := c.registerTools(root)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid tool name override for command "cli list"`)
+		assert.Len(t, c.tools, 1)
+	})
+
+	t.Run("invalid derived name", func(t *testing.T) {
+		root, _, _ := newTree()
+		root.AddCommand(&cobra.Command{Use: "a:b", Run: func(_ *cobra.Command, _ []string) {}})
+
+		// Only warned about, as before names were checked
+		c := &Config{}
+		require.NoError(t, c.registerTools(root))
+		assert.Contains(t, c.toolEntries, "cli_a:b")
+	})
+
+	t.Run("unique overrides", func(t *testing.T) {
+		root, list, _ := newTree()
+		list.Annotations = map[string]string{AnnotationName: "ls"}
+
+		c := &Config{}
+		require.NoError(t, c.registerTools(root))
+		assert.Contains(t, c.toolEntries, "ls")
+		assert.Contains(t, c.toolEntries, "cli_get")
+	})
+}
+
+func TestRegisterToolsInvalidOutputSchema(t *testing.T) {
+	root := &cobra.Command{Use: "cli"}
+	root.AddCommand(
+		&cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}},
+		&cobra.Command{
+			Use: "pods",
+			Run: func(_ *cobra.Command, _ []string) {},
+			Annotations: map[string]string{
+				AnnotationOutput:       OutputFormatJSON,
+				AnnotationOutputSchema: `{"type":"array","items":{"$ref":"#/$defs/pod"}}`,
+			},
+		},
+	)
+
+	c := &Config{}
+	err := c.registerTools(root)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid output schema for command "cli pods"`)
+	require.Len(t, c.tools, 1)
+	assert.Equal(t, "cli_get", c.tools[0].Name)
+}
+
+func TestCommandPath(t *testing.T) {
+	cmd := buildCommandTree("kubectl", "get", "pods")
+	assert.Equal(t, []string{"get", "pods"}, commandPath(cmd))
+	assert.Empty(t, commandPath(cmd.Root()))
+}
+
+func TestToolTimeout(t *testing.T) {
+	cmd := &cobra.Command{Use: "test"}
+	c := &Config{Timeout: time.Minute}
+
+	// Config timeout is the fallback
+	assert.Equal(t, time.Minute, c.toolTimeout(Selector{}, cmd))
+
+	// Selector timeout overrides config
+	assert.Equal(t, time.Second, c.toolTimeout(Selector{Timeout: time.Second}, cmd))
+
+	// Annotation overrides selector
+	cmd.Annotations = map[string]string{AnnotationTimeout: "5m"}
+	assert.Equal(t, 5*time.Minute, c.toolTimeout(Selector{Timeout: time.Second}, cmd))
+
+	// Invalid annotation is ignored
+	cmd.Annotations = map[string]string{AnnotationTimeout: "soon"}
+	assert.Equal(t, time.Second, c.toolTimeout(Selector{Timeout: time.Second}, cmd))
+}
@@ -0,0 +1,183 @@
+package ophis
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// seeAlsoLimit is the most sibling commands listed in an enriched description.
+const seeAlsoLimit = 5
+
+// DescriptionData is the information about a command available to
+// Selector.DescriptionTemplate and Selector.DescriptionFunc.
+type DescriptionData struct {
+	// Cmd is the command the tool is created from.
+	Cmd *cobra.Command
+	// Description is the default tool description: Long (or Short) followed by examples.
+	Description string
+	// Short and Long are the command's own descriptions.
+	Short, Long string
+	// Example is the command's example text.
+	Example string
+	// UseLine is the full usage line, e.g. "kubectl get pods [flags]".
+	UseLine string
+	// Aliases are the command's aliases.
+	Aliases []string
+	// SuggestFor lists the command names this command is suggested for.
+	SuggestFor []string
+	// ValidArgs lists the valid positional arguments, without completion descriptions.
+	ValidArgs []string
+	// SeeAlso lists up to five available sibling commands.
+	SeeAlso []CommandRef
+	// Parents lists the ancestors of the command that have a description, nearest first.
+	Parents []CommandRef
+}
+
+// CommandRef refers to a related command in DescriptionData.
+type CommandRef struct {
+	// Path is the full command path, e.g. "kubectl get".
+	Path string
+	// Description is the command's Long text, or Short if it has no Long text.
+	// For SeeAlso entries it is always Short.
+	Description string
+}
+
+// descriptionFuncs are the functions available to description templates.
+var descriptionFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"truncate": truncateText,
+}
+
+// toolDescription returns the description of the tool created from cmd.
+// DescriptionFunc takes precedence over DescriptionTemplate; if neither is set,
+// the default description is used, enriched if EnrichDescription is set.
+func (s Selector) toolDescription(cmd *cobra.Command) string {
+	if s.DescriptionFunc == nil && s.DescriptionTemplate == "" && !s.EnrichDescription {
+		return toolDescription(cmd)
+	}
+
+	data := newDescriptionData(cmd)
+	switch {
+	case s.DescriptionFunc != nil:
+		return s.DescriptionFunc(data)
+	case s.DescriptionTemplate != "":
+		desc, err := executeDescriptionTemplate(s.DescriptionTemplate, data)
+		if err != nil {
+			slog.Warn("failed to render description template, using default description", "command", cmd.CommandPath(), "error", err)
+			return data.Description
+		}
+
+		return desc
+	default:
+		return enrichedDescription(data)
+	}
+}
+
+// newDescriptionData collects the description data for cmd.
+func newDescriptionData(cmd *cobra.Command) DescriptionData {
+	data := DescriptionData{
+		Cmd:         cmd,
+		Description: toolDescription(cmd),
+		Short:       cmd.Short,
+		Long:        cmd.Long,
+		Example:     cmd.Example,
+		UseLine:     cmd.UseLine(),
+		Aliases:     cmd.Aliases,
+		SuggestFor:  cmd.SuggestFor,
+		ValidArgs:   validArgs(cmd),
+	}
+
+	if parent := cmd.Parent(); parent != nil {
+		for _, sibling := range parent.Commands() {
+			if len(data.SeeAlso) == seeAlsoLimit {
+				break
+			}
+
+			if sibling == cmd || !sibling.IsAvailableCommand() || !sibling.Runnable() || sibling.Name() == "completion" {
+				continue
+			}
+
+			data.SeeAlso = append(data.SeeAlso, CommandRef{Path: sibling.CommandPath(), Description: sibling.Short})
+		}
+	}
+
+	for parent := cmd.Parent(); parent != nil; parent = parent.Parent() {
+		if text := cmp.Or(parent.Long, parent.Short); text != "" {
+			data.Parents = append(data.Parents, CommandRef{Path: parent.CommandPath(), Description: text})
+		}
+	}
+
+	return data
+}
+
+// enrichedDescription appends usage, aliases, valid arguments, and related commands
+// to the default description.
+func enrichedDescription(data DescriptionData) string {
+	parts := []string{data.Description, "Usage: " + data.UseLine}
+
+	if len(data.Aliases) > 0 {
+		parts = append(parts, "Aliases: "+strings.Join(data.Aliases, ", "))
+	}
+
+	if len(data.SuggestFor) > 0 {
+		parts = append(parts, "Suggested for: "+strings.Join(data.SuggestFor, ", "))
+	}
+
+	if len(data.ValidArgs) > 0 {
+		parts = append(parts, "Valid arguments: "+strings.Join(data.ValidArgs, ", "))
+	}
+
+	if len(data.SeeAlso) > 0 {
+		lines := []string{"See also:"}
+		for _, ref := range data.SeeAlso {
+			if ref.Description != "" {
+				lines = append(lines, fmt.Sprintf("- %s: %s", ref.Path, ref.Description))
+			} else {
+				lines = append(lines, "- "+ref.Path)
+			}
+		}
+
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+
+	for _, ref := range data.Parents {
+		parts = append(parts, fmt.Sprintf("About %s:\n%s", ref.Path, ref.Description))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// executeDescriptionTemplate renders text with data.
+func executeDescriptionTemplate(text string, data DescriptionData) (string, error) {
+	tmpl, err := template.New("description").Funcs(descriptionFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// truncateText shortens s to at most n runes, ending it with "..." when it is cut.
+func truncateText(n int, s string) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	if n <= 3 {
+		return string(runes[:n])
+	}
+
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,98 @@
+package ophis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func descriptionTestCmd() *cobra.Command {
+	run := func(_ *cobra.Command, _ []string) {}
+	root := &cobra.Command{Use: "kubectl", Long: "kubectl controls the Kubernetes cluster manager."}
+	get := &cobra.Command{Use: "get", Short: "Display resources"}
+	pods := &cobra.Command{
+		Use:        "pods [name]",
+		Short:      "List pods",
+		Example:    "kubectl get pods web",
+		Aliases:    []string{"po", "pod"},
+		SuggestFor: []string{"containers"},
+		ValidArgs:  []string{"web\tWeb server", "db"},
+		Run:        run,
+	}
+	root.AddCommand(get)
+	get.AddCommand(
+		pods,
+		&cobra.Command{Use: "services", Short: "List services", Run: run},
+		&cobra.Command{Use: "nodes", Run: run},
+		&cobra.Command{Use: "secrets", Hidden: true, Run: run},
+		&cobra.Command{Use: "group", Short: "Not runnable"},
+	)
+	return pods
+}
+
+func TestNewDescriptionData(t *testing.T) {
+	data := newDescriptionData(descriptionTestCmd())
+
+	assert.Equal(t, "List pods\nExamples:\nkubectl get pods web", data.Description)
+	assert.Equal(t, "kubectl get pods [name]", data.UseLine)
+	assert.Equal(t, []string{"po", "pod"}, data.Aliases)
+	assert.Equal(t, []string{"containers"}, data.SuggestFor)
+	assert.Equal(t, []string{"web", "db"}, data.ValidArgs)
+	assert.Equal(t, []CommandRef{
+		{Path: "kubectl get nodes"},
+		{Path: "kubectl get services", Description: "List services"},
+	}, data.SeeAlso)
+	assert.Equal(t, []CommandRef{
+		{Path: "kubectl get", Description: "Display resources"},
+		{Path: "kubectl", Description: "kubectl controls the Kubernetes cluster manager."},
+	}, data.Parents)
+}
+
+func TestSelectorToolDescription(t *testing.T) {
+	cmd := descriptionTestCmd()
+
+	t.Run("default", func(t *testing.T) {
+		assert.Equal(t, toolDescription(cmd), Selector{}.toolDescription(cmd))
+	})
+
+	t.Run("enriched", func(t *testing.T) {
+		desc := Selector{EnrichDescription: true}.toolDescription(cmd)
+		assert.True(t, strings.HasPrefix(desc, toolDescription(cmd)))
+		assert.Contains(t, desc, "Usage: kubectl get pods [name]")
+		assert.Contains(t, desc, "Aliases: po, pod")
+		assert.Contains(t, desc, "Suggested for: containers")
+		assert.Contains(t, desc, "Valid arguments: web, db")
+		assert.Contains(t, desc, "See also:\n- kubectl get nodes\n- kubectl get services: List services")
+		assert.Contains(t, desc, "About kubectl:\nkubectl controls the Kubernetes cluster manager.")
+	})
+
+	t.Run("template", func(t *testing.T) {
+		s := Selector{
+			EnrichDescription:   true,
+			DescriptionTemplate: `{{.Short}} ({{join .Aliases "|"}}) {{truncate 10 (index .Parents 1).Description}}`,
+		}
+		assert.Equal(t, "List pods (po|pod) kubectl...", s.toolDescription(cmd))
+	})
+
+	t.Run("broken template", func(t *testing.T) {
+		s := Selector{DescriptionTemplate: `{{.Missing}}`}
+		assert.Equal(t, toolDescription(cmd), s.toolDescription(cmd))
+	})
+
+	t.Run("func", func(t *testing.T) {
+		s := Selector{
+			DescriptionTemplate: "ignored",
+			DescriptionFunc:     func(d DescriptionData) string { return d.UseLine },
+		}
+		assert.Equal(t, "kubectl get pods [name]", s.toolDescription(cmd))
+	})
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "short", truncateText(10, "short"))
+	assert.Equal(t, "a long...", truncateText(9, "a long description"))
+	assert.Equal(t, "ab", truncateText(2, "abcdef"))
+	assert.Equal(t, "héllo", truncateText(5, "héllo"))
+}
@@ -1,19 +1,54 @@
 package ophis
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unsafe"
 
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var executablePath = initExecPath()
 
+// killGracePeriod is how long a stopped subprocess has to exit after SIGTERM before it is killed.
+const killGracePeriod = 5 * time.Second
+
+// errorSummaryLines is the number of trailing stderr lines included in a tool error summary.
+const errorSummaryLines = 20
+
+// errToolTimeout is the context cause recorded when a tool call exceeds its timeout.
+var errToolTimeout = errors.New("tool execution timed out")
+
+// toolEntry holds the execution settings resolved for a registered tool.
+type toolEntry struct {
+	cmd            *cobra.Command       // the command the tool was created from
+	path           []string             // command path below the root command
+	timeout        time.Duration        // zero means no timeout
+	maxOutputBytes int                  // zero means unlimited
+	maxOutputLines int                  // zero means unlimited
+	progress       bool                 // stream output as progress notifications when requested
+	jsonOutput     bool                 // parse stdout as JSON into the result field
+	resultSchema   *jsonschema.Resolved // validates the parsed result; nil skips validation
+	validator      *inputValidator      // validates call arguments; nil skips validation
+}
+
 func initExecPath() string {
 	path, err := os.Executable()
 	if err != nil {
@@ -23,101 +58,487 @@ func initExecPath() string {
 	return path
 }
 
-// Execute runs the underlying CLI command.
-func execute(ctx context.Context, request *mcp.CallToolRequest, input ToolInput) (*mcp.CallToolResult, ToolOutput, error) {
+// execute runs the underlying CLI command.
+func (c *Config) execute(ctx context.Context, request *mcp.CallToolRequest, input ToolInput) (*mcp.CallToolResult, ToolOutput, error) {
 	name := request.Params.Name
 	slog.Info("mcp tool request received", "request", name)
 
-	// Build command arguments
-	args := buildCommandArgs(name, input)
+	// Look up the settings recorded at registration
+	entry, ok := c.toolEntries[name]
+	if !ok {
+		err := fmt.Errorf("unknown tool %q", name)
+		slog.Error("command failed to run", "name", name, "error", err)
+		return nil, ToolOutput{}, err
+	}
+
+	// Build command arguments, encoding flags by their definitions
+	var fs *pflag.FlagSet
+	if entry.cmd != nil {
+		fs = entry.cmd.Flags()
+	}
+
+	args := buildCommandArgs(entry.path, fs, input)
 	slog.Debug("executing command",
 		"tool", name,
 		"input", input,
 		"args", args,
+		"mode", c.ExecutionMode,
+		"timeout", entry.timeout,
 	)
 
-	// Create exec.Cmd and run it
-	var stdout, stderr bytes.Buffer
+	// Progress notifications outlive the timeout so trailing output is still reported
+	var progress *progressNotifier
+	if entry.progress {
+		progress = newProgressNotifier(ctx, request, entry.maxOutputBytes, entry.maxOutputLines)
+	}
+
+	if entry.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, entry.timeout, errToolTimeout)
+		defer cancel()
+	}
+
+	stdout := newTruncatingWriter(entry.maxOutputBytes, entry.maxOutputLines)
+	stderr := newTruncatingWriter(entry.maxOutputBytes, entry.maxOutputLines)
+	outWriter, errWriter, flushProgress := teeProgress(progress, stdout, stderr)
+
+	var (
+		exitCode int
+		err      error
+	)
+	if c.ExecutionMode == ExecutionModeInProcess {
+		exitCode, err = c.executeInProcess(ctx, args, input, outWriter, errWriter)
+	} else {
+		exitCode, err = executeSubprocess(ctx, args, outWriter, errWriter)
+	}
+	flushProgress()
+
+	if err != nil {
+		slog.Error("command failed to run", "name", name, "error", err)
+		return nil, ToolOutput{}, err
+	}
+
+	output := ToolOutput{
+		StdOut:    stdout.String(),
+		StdErr:    stderr.String(),
+		ExitCode:  exitCode,
+		Truncated: stdout.Truncated() || stderr.Truncated(),
+	}
+
+	if output.Truncated {
+		slog.Debug("command output truncated", "name", name, "stdout_bytes", stdout.total, "stderr_bytes", stderr.total)
+	}
+
+	if entry.jsonOutput {
+		if result, ok := parseJSONOutput(output.StdOut); !ok {
+			slog.Debug("command output is not JSON, returning text", "name", name)
+		} else if err := validateResult(entry.resultSchema, result); err != nil {
+			slog.Warn("command output does not match its output schema, returning text", "name", name, "error", err)
+			return &mcp.CallToolResult{
+				IsError: true,
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("command output does not match its output schema: %v", err)}},
+			}, output, nil
+		} else {
+			output.Result = result
+			output.StdOut = ""
+		}
+	}
+
+	if errors.Is(context.Cause(ctx), errToolTimeout) {
+		slog.Warn("command timed out", "name", name, "timeout", entry.timeout)
+		output.TimedOut = true
+	}
+
+	if c.errorPolicy()(output) {
+		return errorResult(output, entry.timeout), output, nil
+	}
+
+	return nil, output, nil
+}
+
+// parseJSONOutput parses stdout as a single JSON value.
+// Numbers are kept as json.Number so large integers survive the round trip.
+func parseJSONOutput(stdout string) (any, bool) {
+	if strings.TrimSpace(stdout) == "" {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	dec.UseNumber()
+
+	var result any
+	if err := dec.Decode(&result); err != nil {
+		return nil, false
+	}
+
+	// Reject trailing data after the first value
+	if err := dec.Decode(new(json.RawMessage)); err != io.EOF {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// validateResult checks a parsed result against schema. A nil schema accepts any result.
+func validateResult(schema *jsonschema.Resolved, result any) error {
+	if schema == nil {
+		return nil
+	}
+
+	// The validator does not understand json.Number, so validate plain JSON values
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return err
+	}
+
+	return schema.Validate(instance)
+}
+
+// errorResult builds a tool error result whose text summarizes the failure and the end of stderr.
+func errorResult(output ToolOutput, timeout time.Duration) *mcp.CallToolResult {
+	var summary string
+	if output.TimedOut {
+		summary = fmt.Sprintf("command timed out after %s", timeout)
+	} else {
+		summary = fmt.Sprintf("command failed with exit code %d", output.ExitCode)
+	}
+
+	if stderr := strings.TrimSpace(output.StdErr); stderr != "" {
+		summary += ":\n" + stderr[lastLinesStart([]byte(stderr), errorSummaryLines):]
+	}
+
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: summary}},
+	}
+}
+
+// executeSubprocess re-executes the current binary with args and returns its exit code.
+// When ctx is done the child receives SIGTERM, and is killed if it is still running after killGracePeriod.
+func executeSubprocess(ctx context.Context, args []string, stdout, stderr io.Writer) (int, error) {
 	cmd := exec.CommandContext(ctx, executablePath, args...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Cancel = func() error { return terminate(cmd.Process) }
+	cmd.WaitDelay = killGracePeriod
 	exitCode := 0
 
 	err := cmd.Run()
 	if err != nil {
-		// Check if it's an ExitError to get the exit code
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			// Check if it's an ExitError to get the exit code
 			exitCode = exitErr.ExitCode()
-		} else {
+		case errors.Is(err, exec.ErrWaitDelay):
+			// The child exited but left its output pipes open past the grace period
+			exitCode = -1
+		case ctx.Err() != nil && errors.Is(err, ctx.Err()):
+			// The child stopped cleanly after SIGTERM; the caller reports the timeout
+			exitCode = 0
+		default:
 			// Non-exit errors (like command not found)
-			slog.Error("command failed to run", "name", name, "error", err)
-			return nil, ToolOutput{}, err
+			return 0, err
 		}
 	}
 
-	return nil, ToolOutput{
-		StdOut:   stdout.String(),
-		StdErr:   stderr.String(),
-		ExitCode: exitCode,
-	}, nil
+	return exitCode, nil
+}
+
+// executeInProcess runs args against the root command without spawning a subprocess and returns its exit code.
+// Calls are serialized because cobra commands and their flags are shared mutable state.
+func (c *Config) executeInProcess(ctx context.Context, args []string, input ToolInput, stdout, stderr io.Writer) (int, error) {
+	release, err := c.acquireExecSlot(ctx)
+	if err != nil {
+		if errors.Is(context.Cause(ctx), errToolTimeout) {
+			// Reported like a command that ran out of time
+			_, _ = fmt.Fprintln(stderr, err)
+			return -1, nil
+		}
+
+		return 0, err
+	}
+	defer release()
+
+	root := c.rootCmd
+	if root == nil {
+		return 0, fmt.Errorf("in-process execution requires registered tools")
+	}
+
+	// Reset flags left over from previous calls. Cobra only hands the root's context to
+	// a subcommand whose context is nil, so the commands on the path get this call's
+	// context directly, and lose it afterwards.
+	if target, _, err := root.Find(args); err == nil {
+		defer func() {
+			for cmd := target; cmd != nil; cmd = cmd.Parent() {
+				cmd.SetContext(nil)
+			}
+		}()
+
+		for cmd := target; cmd != nil; cmd = cmd.Parent() {
+			if err := resetFlags(cmd.Flags(), input.Flags); err != nil {
+				return 0, err
+			}
+
+			if err := resetFlags(cmd.PersistentFlags(), input.Flags); err != nil {
+				return 0, err
+			}
+
+			cmd.SetContext(ctx)
+		}
+	}
+
+	// Capture output, and keep the command away from the server's stdio
+	prevIn, prevOut, prevErr := root.InOrStdin(), root.OutOrStdout(), root.ErrOrStderr()
+	root.SetIn(strings.NewReader(""))
+	root.SetOut(stdout)
+	root.SetErr(stderr)
+	root.SetArgs(args)
+	defer func() {
+		root.SetIn(prevIn)
+		root.SetOut(prevOut)
+		root.SetErr(prevErr)
+		root.SetArgs(nil)
+	}()
+
+	if err := root.ExecuteContext(ctx); err != nil {
+		return exitCodeFromError(err), nil
+	}
+
+	return 0, nil
+}
+
+// acquireExecSlot waits until no other in-process call is running, and returns a function
+// that lets the next call run. It gives up when ctx is done, so that a command that ignores
+// its context cannot keep later calls from timing out or being cancelled.
+func (c *Config) acquireExecSlot(ctx context.Context) (func(), error) {
+	c.execSlotOnce.Do(func() { c.execSlot = make(chan struct{}, 1) })
+
+	select {
+	case c.execSlot <- struct{}{}:
+		return func() { <-c.execSlot }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for another in-process call: %w", context.Cause(ctx))
+	}
+}
+
+// terminate asks p to stop. Windows has no SIGTERM, so the process is killed outright there.
+func terminate(p *os.Process) error {
+	if runtime.GOOS == "windows" {
+		return p.Kill()
+	}
+
+	return p.Signal(syscall.SIGTERM)
+}
+
+// exitCodeFromError maps an error returned by an in-process command to an exit code.
+// Errors that implement ExitCode() int (such as *exec.ExitError) supply their own code;
+// all other errors map to 1.
+func exitCodeFromError(err error) int {
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	return 1
+}
+
+// resetFlags restores every flag in fs to its default value and clears its Changed state.
+// Slice flags that are about to be set by provided are emptied instead, because pflag
+// appends to a slice that was changed by an earlier parse.
+// It returns an error naming the first flag that could not be reset, since running the
+// command anyway would use values left over from an earlier call.
+func resetFlags(fs *pflag.FlagSet, provided map[string]any) error {
+	var resetErr error
+	fs.VisitAll(func(flag *pflag.Flag) {
+		if resetErr != nil {
+			return
+		}
+
+		var err error
+		typ := flag.Value.Type()
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			if _, ok := provided[flag.Name]; ok {
+				err = slice.Replace([]string{})
+			} else {
+				err = slice.Replace(parseSliceDefault(flag.DefValue))
+			}
+		} else if strings.HasPrefix(typ, "stringTo") {
+			err = resetMapFlag(flag)
+		} else if strings.HasSuffix(typ, "Slice") || strings.HasSuffix(typ, "Array") {
+			// Setting the default would append to the values of the last call
+			err = fmt.Errorf("slice value %T does not implement pflag.SliceValue", flag.Value)
+		} else {
+			err = flag.Value.Set(flag.DefValue)
+		}
+
+		if err != nil {
+			resetErr = fmt.Errorf("failed to reset flag %q to its default: %w", flag.Name, err)
+			return
+		}
+
+		flag.Changed = false
+	})
+
+	return resetErr
+}
+
+// resetMapFlag restores a map flag (stringToString, stringToInt, stringToInt64) to its default.
+// Once pflag has set a map flag it merges later values into the map, and it cannot parse an
+// empty default, so the map and its changed state are reset directly instead of through Set.
+func resetMapFlag(flag *pflag.Flag) error {
+	v := reflect.ValueOf(flag.Value)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported map flag value %T", flag.Value)
+	}
+
+	value, changed := v.Elem().FieldByName("value"), v.Elem().FieldByName("changed")
+	if value.Kind() != reflect.Pointer || value.Type().Elem().Kind() != reflect.Map || changed.Kind() != reflect.Bool {
+		return fmt.Errorf("unsupported map flag value %T", flag.Value)
+	}
+
+	mapPtr, changedPtr := settableField(value), settableField(changed)
+	mapPtr.Elem().Set(reflect.MakeMap(value.Type().Elem()))
+	changedPtr.SetBool(false)
+
+	// Map flags render their default as "[k=v,...]" but parse "k=v,..."
+	if inner := strings.TrimSuffix(strings.TrimPrefix(flag.DefValue, "["), "]"); inner != "" {
+		if err := flag.Value.Set(inner); err != nil {
+			return err
+		}
+
+		// The next parse replaces the default rather than merging into it
+		changedPtr.SetBool(false)
+	}
+
+	return nil
+}
+
+// settableField returns a settable view of an unexported struct field.
+func settableField(field reflect.Value) reflect.Value {
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
+
+// parseSliceDefault parses pflag's slice representation ("[item1,item2]") into its items.
+func parseSliceDefault(defValue string) []string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(defValue, "["), "]")
+	if inner == "" {
+		return []string{}
+	}
+
+	return strings.Split(inner, ",")
 }
 
 // buildCommandArgs constructs CLI arguments from the MCP request.
-func buildCommandArgs(name string, input ToolInput) []string {
-	// Start with the command path (e.g., "root_sub_command" -> ["root", "sub", "command"])
-	// And remove the root command prefix
-	args := strings.Split(name, "_")[1:]
+// path is the command path below the root command (e.g., ["sub", "command"]).
+// fs holds the command's flags, which decide how each flag value is encoded; it may be nil.
+func buildCommandArgs(path []string, fs *pflag.FlagSet, input ToolInput) []string {
+	// Start with the command path
+	args := slices.Clone(path)
 
 	// Add flags
-	flagArgs := buildFlagArgs(input.Flags)
+	flagArgs := buildFlagArgs(fs, input.Flags)
 	args = append(args, flagArgs...)
 
+	// Stop flag parsing so positional arguments that start with a dash are not read as flags
+	if slices.ContainsFunc(input.Args, func(arg string) bool { return strings.HasPrefix(arg, "-") }) {
+		args = append(args, "--")
+	}
+
 	// Add positional arguments
 	return append(args, input.Args...)
 }
 
 // buildFlagArgs converts MCP flags to CLI flag arguments.
-func buildFlagArgs(flagMap map[string]any) []string {
+// Flags are looked up in fs to choose their encoding; unknown flags, or a nil fs,
+// fall back to encoding by value alone.
+//
+// The output is deterministic: flags are emitted sorted by name, array items in
+// their given order, and map entries sorted by key.
+func buildFlagArgs(fs *pflag.FlagSet, flagMap map[string]any) []string {
 	var args []string
 
-	for name, value := range flagMap {
+	for _, name := range slices.Sorted(maps.Keys(flagMap)) {
+		value := flagMap[name]
 		if name == "" || value == nil {
 			continue
 		}
 
+		var flag *pflag.Flag
+		if fs != nil {
+			flag = fs.Lookup(name)
+		}
+
 		if items, ok := value.([]any); ok {
 			for _, item := range items {
-				args = append(args, parseFlagArgValue(name, item)...)
+				args = append(args, parseFlagArgValue(flag, name, item)...)
 			}
 
 			continue
 		}
 
 		if mapVal, ok := value.(map[string]any); ok {
-			for k, v := range mapVal {
-				args = append(args, fmt.Sprintf("--%s", name), fmt.Sprintf("%s=%v", k, v))
+			for _, k := range slices.Sorted(maps.Keys(mapVal)) {
+				args = append(args, flagArg(flag, name, k+"="+formatFlagValue(mapVal[k]))...)
 			}
 
 			continue
 		}
 
-		args = append(args, parseFlagArgValue(name, value)...)
+		args = append(args, parseFlagArgValue(flag, name, value)...)
 	}
 
 	return args
 }
 
-func parseFlagArgValue(name string, value any) (retVal []string) {
-	if value != nil {
-		switch v := value.(type) {
-		case bool:
-			if v {
-				retVal = append(retVal, fmt.Sprintf("--%s", name))
-			}
-		default:
-			retVal = append(retVal, fmt.Sprintf("--%s", name), fmt.Sprintf("%v", value))
+// parseFlagArgValue encodes a single flag value. flag may be nil if the flag is unknown.
+//
+// Booleans for bool flags are encoded as a bare --name for true. False is encoded as
+// --name=false when the flag defaults to true, and is otherwise omitted, since
+// leaving the flag unset has the same effect.
+func parseFlagArgValue(flag *pflag.Flag, name string, value any) (retVal []string) {
+	if value == nil {
+		return nil
+	}
+
+	if v, ok := value.(bool); ok && (flag == nil || flag.Value.Type() == "bool") {
+		switch {
+		case v:
+			retVal = append(retVal, fmt.Sprintf("--%s", name))
+		case flag != nil && flag.DefValue == "true":
+			retVal = append(retVal, fmt.Sprintf("--%s=false", name))
 		}
+
+		return retVal
+	}
+
+	return flagArg(flag, name, formatFlagValue(value))
+}
+
+// formatFlagValue formats a single JSON value as a command line value.
+// JSON numbers arrive as float64, which %v prints in exponent form when large
+// (1e+07), so they are written in plain decimal notation for pflag to parse.
+func formatFlagValue(value any) string {
+	if v, ok := value.(float64); ok {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// flagArg encodes --name with value. The --name=value form is used when two argv
+// entries would be misread: for flags with an optional value (NoOptDefVal), which only
+// take a value after "=", and for values that start with a dash, which pflag would
+// read as another flag. Otherwise the value is passed as its own argv entry.
+func flagArg(flag *pflag.Flag, name, value string) []string {
+	if (flag != nil && flag.NoOptDefVal != "") || strings.HasPrefix(value, "-") {
+		return []string{fmt.Sprintf("--%s=%s", name, value)}
 	}
 
-	return retVal
+	return []string{fmt.Sprintf("--%s", name), value}
 }
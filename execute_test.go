@@ -1,9 +1,22 @@
 package ophis
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildFlagArgs(t *testing.T) {
@@ -127,7 +140,7 @@ func TestBuildFlagArgs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := buildFlagArgs(tt.flags)
+			result := buildFlagArgs(nil, tt.flags)
 			// Sort both slices for comparison since map iteration order is not guaranteed
 			assert.ElementsMatch(t, tt.expected, result)
 		})
@@ -137,13 +150,13 @@ func TestBuildFlagArgs(t *testing.T) {
 func TestBuildCommandArgs(t *testing.T) {
 	tests := []struct {
 		name         string
-		commandName  string
+		path         []string
 		input        ToolInput
 		expectedArgs []string
 	}{
 		{
-			name:        "Simple command",
-			commandName: "root_test",
+			name: "Simple command",
+			path: []string{"test"},
 			input: ToolInput{
 				Flags: map[string]any{},
 				Args:  []string{},
@@ -151,8 +164,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"test"},
 		},
 		{
-			name:        "Nested command",
-			commandName: "root_sub_command",
+			name: "Nested command",
+			path: []string{"sub", "command"},
 			input: ToolInput{
 				Flags: map[string]any{},
 				Args:  []string{},
@@ -160,8 +173,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"sub", "command"},
 		},
 		{
-			name:        "Command with flags",
-			commandName: "root_test",
+			name: "Command with flags",
+			path: []string{"test"},
 			input: ToolInput{
 				Flags: map[string]any{
 					"verbose": true,
@@ -172,8 +185,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"test", "--verbose", "--output", "result.txt"},
 		},
 		{
-			name:        "Command with arguments",
-			commandName: "root_test",
+			name: "Command with arguments",
+			path: []string{"test"},
 			input: ToolInput{
 				Flags: map[string]any{},
 				Args:  []string{"file1.txt", "file2.txt"},
@@ -181,8 +194,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"test", "file1.txt", "file2.txt"},
 		},
 		{
-			name:        "Command with flags and arguments",
-			commandName: "root_deploy",
+			name: "Command with flags and arguments",
+			path: []string{"deploy"},
 			input: ToolInput{
 				Flags: map[string]any{
 					"namespace": "production",
@@ -194,8 +207,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"deploy", "--namespace", "production", "--replicas", "3", "--wait", "my-app", "v1.2.3"},
 		},
 		{
-			name:        "Complex nested command",
-			commandName: "root_cluster_node_list",
+			name: "Complex nested command",
+			path: []string{"cluster", "node", "list"},
 			input: ToolInput{
 				Flags: map[string]any{
 					"output": "json",
@@ -206,8 +219,8 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"cluster", "node", "list", "--output", "json", "--label", "env=prod", "--label", "team=backend"},
 		},
 		{
-			name:        "Command with map flags",
-			commandName: "root_deploy",
+			name: "Command with map flags",
+			path: []string{"deploy"},
 			input: ToolInput{
 				Flags: map[string]any{
 					"labels": map[string]any{
@@ -221,19 +234,29 @@ func TestBuildCommandArgs(t *testing.T) {
 			expectedArgs: []string{"deploy", "--labels", "env=production", "--labels", "version=v1.2.3", "--wait", "my-app"},
 		},
 		{
-			name:        "Command with quoted arguments",
-			commandName: "root_exec",
+			name: "Command with quoted arguments",
+			path: []string{"exec"},
 			input: ToolInput{
 				Flags: map[string]any{},
 				Args:  []string{"argument with spaces", "another quoted arg", "normal"},
 			},
 			expectedArgs: []string{"exec", "argument with spaces", "another quoted arg", "normal"},
 		},
+
+		{
+			name: "Command name with underscore",
+			path: []string{"get_all"},
+			input: ToolInput{
+				Flags: map[string]any{},
+				Args:  []string{"pods"},
+			},
+			expectedArgs: []string{"get_all", "pods"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := buildCommandArgs(tt.commandName, tt.input)
+			result := buildCommandArgs(tt.path, nil, tt.input)
 
 			// Extract command parts for comparison
 			commandParts := len(result) - len(tt.expectedArgs)
@@ -248,3 +271,563 @@ func TestBuildCommandArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildFlagArgsWithFlagSet(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("color", "never", "Colorize output")
+	fs.Lookup("color").NoOptDefVal = "auto"
+	fs.Bool("prune", true, "Prune orphans")
+	fs.Bool("force", false, "Force")
+	fs.Int("offset", 0, "Offset")
+	fs.CountP("verbose", "v", "Verbosity")
+	fs.BoolSlice("checks", nil, "Checks")
+	fs.StringToString("env", nil, "Environment")
+
+	tests := []struct {
+		name     string
+		flags    map[string]any
+		expected []string
+	}{
+		{"optional value", map[string]any{"color": "always"}, []string{"--color=always"}},
+		{"count", map[string]any{"verbose": 2}, []string{"--verbose=2"}},
+		{"negative number", map[string]any{"offset": -5}, []string{"--offset=-5"}},
+		{"value starting with dash", map[string]any{"unknown": "-x"}, []string{"--unknown=-x"}},
+		{"false for true default", map[string]any{"prune": false}, []string{"--prune=false"}},
+		{"false for false default", map[string]any{"force": false}, []string{}},
+		{"true", map[string]any{"force": true}, []string{"--force"}},
+		{"bool slice", map[string]any{"checks": []any{true, false}}, []string{"--checks", "true", "--checks", "false"}},
+		{"map value starting with dash", map[string]any{"env": map[string]any{"-A": "1"}}, []string{"--env=-A=1"}},
+		{"large JSON number", map[string]any{"offset": float64(10000000)}, []string{"--offset", "10000000"}},
+		{"small JSON number", map[string]any{"ratio": 0.00000015}, []string{"--ratio", "0.00000015"}},
+		{"JSON number in map", map[string]any{"env": map[string]any{"size": float64(1e21)}}, []string{"--env", "size=1000000000000000000000"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.expected, buildFlagArgs(fs, tt.flags))
+		})
+	}
+}
+
+func TestBuildFlagArgsOrder(t *testing.T) {
+	flags := map[string]any{
+		"zone":   "a",
+		"label":  map[string]any{"tier": "web", "app": "shop", "env": "prod"},
+		"all":    true,
+		"filter": []any{"z", "a", "m"},
+		"count":  2,
+	}
+	expected := []string{
+		"--all",
+		"--count", "2",
+		"--filter", "z", "--filter", "a", "--filter", "m",
+		"--label", "app=shop", "--label", "env=prod", "--label", "tier=web",
+		"--zone", "a",
+	}
+
+	// Map iteration order is random, so repeat to catch unstable output
+	for range 20 {
+		require.Equal(t, expected, buildFlagArgs(nil, flags))
+	}
+}
+
+func TestBuildCommandArgsDashArgs(t *testing.T) {
+	input := ToolInput{Flags: map[string]any{"n": 1}, Args: []string{"-file", "other"}}
+	assert.Equal(t, []string{"tail", "--n", "1", "--", "-file", "other"}, buildCommandArgs([]string{"tail"}, nil, input))
+}
+
+func TestExecuteInProcessFlagEncoding(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	var (
+		color  string
+		prune  bool
+		offset int
+	)
+
+	list := &cobra.Command{
+		Use: "list",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Printf("%s %t %d %v", color, prune, offset, args)
+		},
+	}
+	list.Flags().StringVar(&color, "color", "never", "Colorize output")
+	list.Flags().Lookup("color").NoOptDefVal = "auto"
+	list.Flags().BoolVar(&prune, "prune", true, "Prune orphans")
+	list.Flags().IntVar(&offset, "offset", 0, "Offset")
+	root.AddCommand(list)
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_list"}}
+	input := ToolInput{
+		Flags: map[string]any{"color": "always", "prune": false, "offset": float64(-30000000)},
+		Args:  []string{"-weird-name"},
+	}
+	_, out, err := c.execute(context.Background(), request, input)
+	require.NoError(t, err)
+	assert.Equal(t, 0, out.ExitCode, out.StdErr)
+	assert.Equal(t, "always false -30000000 [-weird-name]", out.StdOut)
+}
+
+func TestExecuteInProcess(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	var (
+		name string
+		tags []string
+	)
+
+	greet := &cobra.Command{
+		Use: "greet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name == "fail" {
+				return errors.New("bad name")
+			}
+
+			cmd.Printf("hello %s %v %v", name, tags, args)
+			return nil
+		},
+	}
+	greet.Flags().StringVar(&name, "name", "world", "Name to greet")
+	greet.Flags().StringSliceVar(&tags, "tag", []string{"a"}, "Tags")
+	root.AddCommand(greet)
+
+	c := &Config{
+		ExecutionMode: ExecutionModeInProcess,
+		rootCmd:       root,
+		toolEntries:   map[string]*toolEntry{"root_greet": {path: []string{"greet"}}},
+	}
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_greet"}}
+
+	t.Run("flags and args", func(t *testing.T) {
+		input := ToolInput{Flags: map[string]any{"name": "ophis", "tag": []any{"x", "y"}}, Args: []string{"arg"}}
+		_, out, err := c.execute(context.Background(), request, input)
+		require.NoError(t, err)
+		assert.Equal(t, 0, out.ExitCode)
+		assert.Equal(t, "hello ophis [x y] [arg]", out.StdOut)
+	})
+
+	t.Run("flags reset between calls", func(t *testing.T) {
+		input := ToolInput{Flags: map[string]any{"tag": []any{"z"}}}
+		_, out, err := c.execute(context.Background(), request, input)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world [z] []", out.StdOut)
+
+		_, out, err = c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.Equal(t, "hello world [a] []", out.StdOut)
+	})
+
+	t.Run("errors become exit codes", func(t *testing.T) {
+		input := ToolInput{Flags: map[string]any{"name": "fail"}}
+		res, out, err := c.execute(context.Background(), request, input)
+		require.NoError(t, err)
+		assert.Equal(t, 1, out.ExitCode)
+		assert.Contains(t, out.StdErr, "bad name")
+
+		// Non-zero exit codes are tool errors by default
+		require.NotNil(t, res)
+		assert.True(t, res.IsError)
+	})
+
+	t.Run("output limits", func(t *testing.T) {
+		c.toolEntries["root_greet"].maxOutputBytes = 10
+		defer func() { c.toolEntries["root_greet"].maxOutputBytes = 0 }()
+
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.True(t, out.Truncated)
+		assert.Equal(t, "hello\n... [8 bytes truncated] ...\na] []", out.StdOut)
+	})
+}
+
+func TestExecuteInProcessMapFlagReset(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	var (
+		labels map[string]string
+		limits map[string]int
+	)
+
+	apply := &cobra.Command{
+		Use: "apply",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Printf("%v %v", labels, limits)
+		},
+	}
+	apply.Flags().StringToStringVar(&labels, "labels", nil, "Labels")
+	apply.Flags().StringToIntVar(&limits, "limits", map[string]int{"cpu": 1}, "Limits")
+	root.AddCommand(apply)
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_apply"}}
+
+	calls := []struct {
+		flags map[string]any
+		want  string
+	}{
+		{map[string]any{"labels": map[string]any{"a": "1"}, "limits": map[string]any{"mem": 2}}, "map[a:1] map[mem:2]"},
+		{map[string]any{"labels": map[string]any{"b": "2"}}, "map[b:2] map[cpu:1]"},
+		{nil, "map[] map[cpu:1]"},
+	}
+	for _, call := range calls {
+		_, out, err := c.execute(context.Background(), request, ToolInput{Flags: call.flags})
+		require.NoError(t, err)
+		assert.Equal(t, 0, out.ExitCode, out.StdErr)
+		assert.Equal(t, call.want, out.StdOut)
+	}
+}
+
+func TestExecuteInProcessWaitHonorsContext(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	started, unblock := make(chan struct{}), make(chan struct{})
+	root.AddCommand(&cobra.Command{
+		Use: "block",
+		Run: func(_ *cobra.Command, _ []string) {
+			// Ignores its context
+			close(started)
+			<-unblock
+		},
+	})
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_block"}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = c.execute(context.Background(), request, ToolInput{})
+	}()
+	<-started
+
+	t.Run("timeout", func(t *testing.T) {
+		c.toolEntries["root_block"].timeout = 50 * time.Millisecond
+		defer func() { c.toolEntries["root_block"].timeout = 0 }()
+
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.True(t, out.TimedOut)
+		assert.Equal(t, -1, out.ExitCode)
+		assert.Contains(t, out.StdErr, "waiting for another in-process call")
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := c.execute(ctx, request, ToolInput{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	close(unblock)
+	<-done
+}
+
+// labelsValue is a slice flag value that does not implement pflag.SliceValue.
+type labelsValue []string
+
+func (v *labelsValue) String() string     { return "[" + strings.Join(*v, ",") + "]" }
+func (v *labelsValue) Set(s string) error { *v = append(*v, s); return nil }
+func (v *labelsValue) Type() string       { return "labelSlice" }
+
+func TestExecuteInProcessResetFailure(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	var labels labelsValue
+	tag := &cobra.Command{
+		Use: "tag",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Print(labels)
+		},
+	}
+	tag.Flags().Var(&labels, "label", "Labels")
+	root.AddCommand(tag)
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_tag"}}
+
+	// The call fails rather than running with the labels of an earlier call
+	_, _, err := c.execute(context.Background(), request, ToolInput{Flags: map[string]any{"label": "a"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to reset flag "label" to its default`)
+}
+
+// TestPflagInternals pins the pflag internals that in-process execution relies on to
+// reset flags, so that a pflag upgrade that changes them fails here.
+func TestPflagInternals(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringToString("labels", nil, "")
+	fs.StringToInt("limits", nil, "")
+	fs.StringToInt64("quotas", nil, "")
+	fs.StringSlice("strings", nil, "")
+	fs.StringArray("array", nil, "")
+	fs.IntSlice("ints", nil, "")
+	fs.BoolSlice("bools", nil, "")
+	fs.DurationSlice("durations", nil, "")
+
+	t.Run("map flags", func(t *testing.T) {
+		for _, name := range []string{"labels", "limits", "quotas"} {
+			value := reflect.ValueOf(fs.Lookup(name).Value)
+			require.Equal(t, reflect.Pointer, value.Kind(), name)
+
+			field := value.Elem().FieldByName("value")
+			require.Equal(t, reflect.Pointer, field.Kind(), name)
+			assert.Equal(t, reflect.Map, field.Type().Elem().Kind(), name)
+			assert.Equal(t, reflect.Bool, value.Elem().FieldByName("changed").Kind(), name)
+		}
+	})
+
+	t.Run("map flags merge until reset", func(t *testing.T) {
+		flag := fs.Lookup("labels")
+		require.NoError(t, flag.Value.Set("a=1"))
+		require.NoError(t, flag.Value.Set("b=2"))
+		assert.Equal(t, "[a=1,b=2]", flag.Value.String())
+
+		require.NoError(t, resetMapFlag(flag))
+		require.NoError(t, flag.Value.Set("c=3"))
+		assert.Equal(t, "[c=3]", flag.Value.String())
+	})
+
+	t.Run("slice flags", func(t *testing.T) {
+		for _, name := range []string{"strings", "array", "ints", "bools", "durations"} {
+			assert.Implements(t, (*pflag.SliceValue)(nil), fs.Lookup(name).Value, name)
+		}
+	})
+}
+
+func TestExecuteInProcessContext(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	wait := &cobra.Command{
+		Use: "wait",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Context().Err()
+		},
+	}
+	root.AddCommand(wait)
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess, Timeout: time.Minute}
+	c.registerTools(root)
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_wait"}}
+
+	// Each call must see its own context, not the cancelled one of the call before
+	for range 2 {
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, out.ExitCode, out.StdErr)
+	}
+
+	assert.Nil(t, wait.Context())
+}
+
+func TestExecuteUnknownTool(t *testing.T) {
+	c := &Config{toolEntries: map[string]*toolEntry{"root_get_all": {path: []string{"get_all"}}}}
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_get"}}
+
+	_, _, err := c.execute(context.Background(), request, ToolInput{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown tool "root_get"`)
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	t.Run("in-process", func(t *testing.T) {
+		root := &cobra.Command{Use: "root"}
+		root.AddCommand(&cobra.Command{
+			Use: "wait",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				cmd.Print("waiting")
+				<-cmd.Context().Done()
+				return cmd.Context().Err()
+			},
+		})
+
+		c := &Config{
+			ExecutionMode: ExecutionModeInProcess,
+			rootCmd:       root,
+			toolEntries:   map[string]*toolEntry{"root_wait": {path: []string{"wait"}, timeout: 10 * time.Millisecond}},
+		}
+		request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_wait"}}
+
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.True(t, out.TimedOut)
+		assert.Equal(t, 1, out.ExitCode)
+		assert.True(t, strings.HasPrefix(out.StdOut, "waiting"))
+	})
+
+	t.Run("subprocess", func(t *testing.T) {
+		sleep, err := exec.LookPath("sleep")
+		if err != nil {
+			t.Skip("sleep not available")
+		}
+
+		prev := executablePath
+		executablePath = sleep
+		defer func() { executablePath = prev }()
+
+		c := &Config{toolEntries: map[string]*toolEntry{"root_sleep": {path: []string{"10"}, timeout: 10 * time.Millisecond}}}
+		request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_sleep"}}
+
+		start := time.Now()
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.True(t, out.TimedOut)
+		assert.NotEqual(t, 0, out.ExitCode)
+		assert.Less(t, time.Since(start), killGracePeriod)
+	})
+
+	t.Run("subprocess stops cleanly on SIGTERM", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("no SIGTERM on windows")
+		}
+
+		sh, err := exec.LookPath("sh")
+		if err != nil {
+			t.Skip("sh not available")
+		}
+
+		prev := executablePath
+		executablePath = sh
+		defer func() { executablePath = prev }()
+
+		script := `trap 'echo stopping; exit 0' TERM; echo started; while :; do sleep 0.01; done`
+		c := &Config{toolEntries: map[string]*toolEntry{"root_trap": {path: []string{"-c", script}, timeout: 200 * time.Millisecond}}}
+		request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_trap"}}
+
+		_, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.True(t, out.TimedOut)
+		assert.Equal(t, 0, out.ExitCode)
+		assert.Equal(t, "started\nstopping\n", out.StdOut)
+	})
+}
+
+func TestErrorResult(t *testing.T) {
+	t.Run("exit code with stderr", func(t *testing.T) {
+		res := errorResult(ToolOutput{ExitCode: 2, StdErr: "Error: no such pod\n"}, 0)
+		assert.True(t, res.IsError)
+		require.Len(t, res.Content, 1)
+		assert.Equal(t, "command failed with exit code 2:\nError: no such pod", res.Content[0].(*mcp.TextContent).Text)
+	})
+
+	t.Run("timeout without stderr", func(t *testing.T) {
+		res := errorResult(ToolOutput{ExitCode: -1, TimedOut: true}, time.Minute)
+		assert.Equal(t, "command timed out after 1m0s", res.Content[0].(*mcp.TextContent).Text)
+	})
+
+	t.Run("long stderr keeps the last lines", func(t *testing.T) {
+		stderr := strings.Repeat("noise\n", 100) + "root cause"
+		text := errorResult(ToolOutput{ExitCode: 1, StdErr: stderr}, 0).Content[0].(*mcp.TextContent).Text
+		assert.Equal(t, errorSummaryLines, strings.Count(text, "\n"))
+		assert.True(t, strings.HasSuffix(text, "root cause"))
+	})
+}
+
+func TestErrorPolicy(t *testing.T) {
+	assert.True(t, (&Config{}).errorPolicy()(ToolOutput{ExitCode: 1}))
+	assert.True(t, (&Config{}).errorPolicy()(ToolOutput{TimedOut: true}))
+	assert.False(t, (&Config{}).errorPolicy()(ToolOutput{}))
+	assert.False(t, (&Config{ErrorPolicy: NeverError}).errorPolicy()(ToolOutput{ExitCode: 1}))
+}
+
+func TestParseJSONOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		stdout   string
+		expected any
+		ok       bool
+	}{
+		{name: "object", stdout: `{"name":"web","replicas":3}`, expected: map[string]any{"name": "web", "replicas": json.Number("3")}, ok: true},
+		{name: "array with whitespace", stdout: "[1, 2]\n", expected: []any{json.Number("1"), json.Number("2")}, ok: true},
+		{name: "large integer", stdout: `9007199254740993`, expected: json.Number("9007199254740993"), ok: true},
+		{name: "empty", stdout: "  \n", ok: false},
+		{name: "plain text", stdout: "NAME READY\nweb 1/1", ok: false},
+		{name: "trailing data", stdout: `{"a":1} {"b":2}`, ok: false},
+		{name: "truncated", stdout: `{"a":`, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseJSONOutput(tt.stdout)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestExecuteJSONOutput(t *testing.T) {
+	var output string
+	root := &cobra.Command{Use: "root"}
+	root.AddCommand(&cobra.Command{
+		Use: "get",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Print(output)
+		},
+	})
+
+	c := &Config{
+		ExecutionMode: ExecutionModeInProcess,
+		rootCmd:       root,
+		toolEntries:   map[string]*toolEntry{"root_get": {path: []string{"get"}, jsonOutput: true}},
+	}
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_get"}}
+
+	output = `{"items":[]}`
+	_, out, err := c.execute(context.Background(), request, ToolInput{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"items": []any{}}, out.Result)
+	assert.Empty(t, out.StdOut)
+
+	output = "No resources found"
+	_, out, err = c.execute(context.Background(), request, ToolInput{})
+	require.NoError(t, err)
+	assert.Nil(t, out.Result)
+	assert.Equal(t, "No resources found", out.StdOut)
+}
+
+func TestExecuteOutputType(t *testing.T) {
+	type pod struct {
+		Name     string `json:"name"`
+		Replicas int    `json:"replicas"`
+	}
+
+	var output string
+	root := &cobra.Command{Use: "root"}
+	root.AddCommand(WithOutputType[[]pod](&cobra.Command{
+		Use: "pods",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Print(output)
+		},
+	}))
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+	require.Len(t, c.tools, 1)
+
+	// The declared type becomes the result schema
+	result := c.tools[0].OutputSchema.(*jsonschema.Schema).Properties["result"]
+	assert.True(t, hasSchemaType(result, "array"))
+	require.NotNil(t, result.Items)
+	assert.Contains(t, result.Items.Properties, "replicas")
+
+	request := &mcp.CallToolRequest{Params: &mcp.CallToolParamsRaw{Name: "root_pods"}}
+
+	t.Run("matching output", func(t *testing.T) {
+		output = `[{"name":"web","replicas":2}]`
+		res, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		assert.Nil(t, res)
+		assert.Len(t, out.Result, 1)
+	})
+
+	t.Run("mismatched output", func(t *testing.T) {
+		output = `[{"name":"web","replicas":"two"}]`
+		res, out, err := c.execute(context.Background(), request, ToolInput{})
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		assert.True(t, res.IsError)
+		assert.Contains(t, res.Content[0].(*mcp.TextContent).Text, "does not match its output schema")
+		assert.Nil(t, out.Result)
+		assert.Equal(t, output, out.StdOut)
+	})
+}
@@ -0,0 +1,230 @@
+package ophis
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// explainCommandFlags holds flags for the explain command.
+type explainCommandFlags struct {
+	output        string
+	selectorsFile string
+}
+
+// Explain output formats.
+const (
+	explainOutputTable = "table"
+	explainOutputJSON  = "json"
+)
+
+// commandExplanation explains whether a command is exposed as a tool and why.
+type commandExplanation struct {
+	// Command is the full command path.
+	Command string `json:"command"`
+	// Exposed reports whether the command becomes a tool.
+	Exposed bool `json:"exposed"`
+	// Tool is the name of the tool, if a selector matched the command.
+	Tool string `json:"tool,omitempty"`
+	// Filtered is why the basic filters rejected the command: "hidden", "deprecated",
+	// "not runnable", or "built-in".
+	Filtered string `json:"filtered,omitempty"`
+	// Error is why the tool cannot be registered, such as an invalid or duplicate tool name.
+	// The server fails to start while any command has an error.
+	Error string `json:"error,omitempty"`
+	// Selector is the index of the matching selector in Config.Selectors, if any.
+	Selector *int `json:"selector,omitempty"`
+	// FileSelector is the index of the matching entry in the selectors file, if one is used.
+	FileSelector *int `json:"fileSelector,omitempty"`
+	// SelectorDescription describes the matching selector (see Describe).
+	SelectorDescription string `json:"selectorDescription,omitempty"`
+	// Flags explains each flag of an exposed command.
+	Flags []flagExplanation `json:"flags,omitempty"`
+}
+
+// flagExplanation explains whether a flag is included in a tool and why.
+type flagExplanation struct {
+	// Name is the flag name.
+	Name string `json:"name"`
+	// Inherited reports whether the flag is inherited from a parent command.
+	Inherited bool `json:"inherited"`
+	// Kept reports whether the flag is included in the tool's input schema.
+	Kept bool `json:"kept"`
+	// Reason is why the flag was dropped.
+	Reason string `json:"reason,omitempty"`
+}
+
+// explainCommand creates the 'mcp explain' command.
+func explainCommand(config *Config) *cobra.Command {
+	f := &explainCommandFlags{}
+	cmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Explain which commands are exposed as tools",
+		Long:  `Show, for every command, whether it is exposed as a MCP tool, which selector matched it, and which flags were kept or dropped`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if config == nil {
+				config = &Config{}
+			}
+
+			if f.output != explainOutputTable && f.output != explainOutputJSON {
+				return fmt.Errorf("invalid output format %q: must be %q or %q", f.output, explainOutputTable, explainOutputJSON)
+			}
+
+			if f.selectorsFile != "" {
+				config.SelectorsFile = f.selectorsFile
+			}
+
+			if err := config.applySelectorsFile(); err != nil {
+				return err
+			}
+
+			explanations := config.explain(cmd.Root())
+			if f.output == explainOutputJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(explanations)
+			}
+
+			return writeExplanationTable(cmd.OutOrStdout(), explanations)
+		},
+	}
+
+	// Add flags
+	flags := cmd.Flags()
+	flags.StringVarP(&f.output, "output", "o", explainOutputTable, "Output format (table, json)")
+	flags.StringVar(&f.selectorsFile, "selectors-file", "", "YAML or JSON file of selectors that narrows the exposed tools")
+	return cmd
+}
+
+// explain explains every command in the tree under root, in the order of the command tree,
+// applying the same filters, selectors and tool name checks as registerTools.
+func (c *Config) explain(root *cobra.Command) []commandExplanation {
+	if len(c.Selectors) == 0 {
+		c.Selectors = []Selector{{}}
+	}
+
+	prefix := cmp.Or(c.ToolNamePrefix, root.Name())
+	toolCmds := make(map[string]*cobra.Command)
+	usedBy := func(name string) (*cobra.Command, bool) {
+		cmd, ok := toolCmds[name]
+		return cmd, ok
+	}
+
+	var explanations []commandExplanation
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		// Explain subcommands first, as registerToolsRecursive registers them,
+		// so a name conflict is reported on the same command
+		i := len(explanations)
+		explanations = append(explanations, commandExplanation{})
+		for _, subCmd := range cmd.Commands() {
+			walk(subCmd)
+		}
+
+		explanations[i] = c.explainCmd(cmd, prefix, usedBy)
+		if explanations[i].Exposed {
+			toolCmds[explanations[i].Tool] = cmd
+		}
+	}
+
+	walk(root)
+	return explanations
+}
+
+// explainCmd explains whether cmd is exposed as a tool.
+// usedBy looks up the command that already uses a tool name.
+func (c *Config) explainCmd(cmd *cobra.Command, toolNamePrefix string, usedBy func(name string) (*cobra.Command, bool)) commandExplanation {
+	explanation := commandExplanation{Command: cmd.CommandPath()}
+	if reason := c.filterReason(cmd); reason != "" {
+		explanation.Filtered = reason
+		return explanation
+	}
+
+	i, s, ok := c.matchSelector(cmd)
+	if !ok {
+		return explanation
+	}
+
+	explanation.Tool = s.toolName(cmd, toolNamePrefix)
+	explanation.Selector = &i
+	if s.narrowed != nil {
+		explanation.Selector = &s.narrowed.selector
+		explanation.FileSelector = &s.narrowed.fileSelector
+	}
+
+	explanation.SelectorDescription = s.String()
+	if err := s.checkToolName(cmd, explanation.Tool, usedBy); err != nil {
+		explanation.Error = err.Error()
+		return explanation
+	}
+
+	if _, err := checkOutputSchema(cmd, toolOutputSchema(cmd)); err != nil {
+		explanation.Error = err.Error()
+		return explanation
+	}
+
+	explanation.Exposed = true
+
+	// mirror enhanceFlagsSchema
+	cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		explanation.Flags = append(explanation.Flags, explainFlag(s, flag, false))
+	})
+
+	cmd.InheritedFlags().VisitAll(func(flag *pflag.Flag) {
+		explanation.Flags = append(explanation.Flags, explainFlag(s, flag, true))
+	})
+
+	return explanation
+}
+
+// explainFlag explains whether flag is included in tools created by s.
+func explainFlag(s Selector, flag *pflag.Flag, inherited bool) flagExplanation {
+	reason := s.dropFlagReason(flag, inherited)
+	return flagExplanation{
+		Name:      flag.Name,
+		Inherited: inherited,
+		Kept:      reason == "",
+		Reason:    reason,
+	}
+}
+
+// writeExplanationTable writes explanations as a table, with the flags of each exposed
+// command on the lines below it.
+func writeExplanationTable(out io.Writer, explanations []commandExplanation) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND\tSTATUS\tTOOL\tDETAIL")
+	for _, e := range explanations {
+		switch {
+		case e.Filtered != "":
+			fmt.Fprintf(w, "%s\tfiltered\t-\t%s\n", e.Command, e.Filtered)
+		case e.Error != "":
+			fmt.Fprintf(w, "%s\terror\t%s\t%s\n", e.Command, e.Tool, e.Error)
+		case !e.Exposed:
+			fmt.Fprintf(w, "%s\tunmatched\t-\tno selector matched\n", e.Command)
+		case e.FileSelector != nil:
+			fmt.Fprintf(w, "%s\texposed\t%s\tselector %d, file selector %d: %s\n", e.Command, e.Tool, *e.Selector, *e.FileSelector, e.SelectorDescription)
+		default:
+			fmt.Fprintf(w, "%s\texposed\t%s\tselector %d: %s\n", e.Command, e.Tool, *e.Selector, e.SelectorDescription)
+		}
+
+		for _, flag := range e.Flags {
+			name := "  --" + flag.Name
+			if flag.Inherited {
+				name += " (inherited)"
+			}
+
+			if flag.Kept {
+				fmt.Fprintf(w, "%s\tkept\n", name)
+			} else {
+				fmt.Fprintf(w, "%s\tdropped\t\t%s\n", name, flag.Reason)
+			}
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,206 @@
+package ophis
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildExplainTree builds a CLI with an exposed, a hidden, an unmatched, and a group command.
+func buildExplainTree(config *Config) *cobra.Command {
+	root := &cobra.Command{Use: "kubectl"}
+	root.PersistentFlags().String("kubeconfig", "", "kubeconfig file")
+
+	get := &cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}}
+	get.Flags().String("namespace", "", "namespace")
+	get.Flags().String("token", "", "token")
+	get.Flags().String("old", "", "old flag")
+	_ = get.Flags().MarkDeprecated("old", "use namespace")
+
+	secret := &cobra.Command{Use: "secret", Hidden: true, Run: func(_ *cobra.Command, _ []string) {}}
+	del := &cobra.Command{Use: "delete", Run: func(_ *cobra.Command, _ []string) {}}
+	config2 := &cobra.Command{Use: "config"}
+
+	root.AddCommand(get, secret, del, config2, Command(config))
+	return root
+}
+
+func TestExplain(t *testing.T) {
+	config := &Config{
+		Selectors: []Selector{{
+			CmdSelector:           AllowCmds("kubectl get"),
+			LocalFlagSelector:     ExcludeFlags("token"),
+			InheritedFlagSelector: NoFlags,
+		}},
+	}
+
+	explanations := config.explain(buildExplainTree(config))
+	byCommand := make(map[string]commandExplanation, len(explanations))
+	for _, e := range explanations {
+		byCommand[e.Command] = e
+	}
+
+	assert.Equal(t, "kubectl", explanations[0].Command)
+	assert.Equal(t, "not runnable", byCommand["kubectl"].Filtered)
+	assert.Equal(t, "hidden", byCommand["kubectl secret"].Filtered)
+	assert.Equal(t, "not runnable", byCommand["kubectl config"].Filtered)
+	assert.Equal(t, "built-in", byCommand["kubectl mcp start"].Filtered)
+
+	del := byCommand["kubectl delete"]
+	assert.False(t, del.Exposed)
+	assert.Empty(t, del.Filtered)
+	assert.Nil(t, del.Selector)
+
+	get := byCommand["kubectl get"]
+	assert.True(t, get.Exposed)
+	assert.Equal(t, "kubectl_get", get.Tool)
+	require.NotNil(t, get.Selector)
+	assert.Equal(t, 0, *get.Selector)
+	assert.Equal(t, `cmds=AllowCmds("kubectl get") local_flags=ExcludeFlags("token") inherited_flags=NoFlags`, get.SelectorDescription)
+	assert.Equal(t, []flagExplanation{
+		{Name: "namespace", Kept: true},
+		{Name: "old", Reason: "deprecated"},
+		{Name: "token", Reason: `rejected by LocalFlagSelector ExcludeFlags("token")`},
+		{Name: "kubeconfig", Inherited: true, Reason: "rejected by InheritedFlagSelector NoFlags"},
+	}, get.Flags)
+}
+
+func TestExplainToolNameErrors(t *testing.T) {
+	root := &cobra.Command{Use: "cli"}
+	list := &cobra.Command{Use: "list", Run: func(_ *cobra.Command, _ []string) {}}
+	get := &cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}}
+	bad := &cobra.Command{
+		Use:         "bad",
+		Run:         func(_ *cobra.Command, _ []string) {},
+		Annotations: map[string]string{AnnotationName: "a:b"},
+	}
+	derived := &cobra.Command{Use: "db:migrate", Run: func(_ *cobra.Command, _ []string) {}}
+	root.AddCommand(list, get, bad, derived)
+
+	selectors := []Selector{{Overrides: map[string]ToolOverride{"cli list": {Name: "cli_get"}}}}
+
+	// registerTools and explain reject the same command
+	err := (&Config{Selectors: selectors}).registerTools(root)
+	require.Error(t, err)
+
+	byCommand := make(map[string]commandExplanation)
+	for _, e := range (&Config{Selectors: selectors}).explain(root) {
+		byCommand[e.Command] = e
+	}
+
+	// Subcommands are sorted, so "cli get" claims the name first
+	assert.True(t, byCommand["cli get"].Exposed)
+	assert.False(t, byCommand["cli list"].Exposed)
+	assert.Equal(t, `tool name "cli_get" of command "cli list" is already used by command "cli get"`, byCommand["cli list"].Error)
+	assert.Contains(t, err.Error(), byCommand["cli list"].Error)
+
+	assert.Equal(t, `invalid tool name override for command "cli bad": tool name "a:b" contains invalid character ':'`, byCommand["cli bad"].Error)
+	assert.Contains(t, err.Error(), byCommand["cli bad"].Error)
+
+	// Names derived from the command path are only warned about
+	assert.True(t, byCommand["cli db:migrate"].Exposed)
+	assert.Empty(t, byCommand["cli db:migrate"].Error)
+}
+
+func TestExplainInvalidOutputSchema(t *testing.T) {
+	root := &cobra.Command{Use: "cli"}
+	root.AddCommand(&cobra.Command{
+		Use: "pods",
+		Run: func(_ *cobra.Command, _ []string) {},
+		Annotations: map[string]string{
+			AnnotationOutputSchema: `{"type":"array","items":{"$ref":"#/$defs/pod"}}`,
+		},
+	})
+
+	// registerTools and explain reject the same command
+	err := (&Config{}).registerTools(root)
+	require.Error(t, err)
+
+	var pods commandExplanation
+	for _, e := range (&Config{}).explain(root) {
+		if e.Command == "cli pods" {
+			pods = e
+		}
+	}
+
+	assert.False(t, pods.Exposed)
+	assert.Contains(t, pods.Error, `invalid output schema for command "cli pods"`)
+	assert.Contains(t, err.Error(), pods.Error)
+}
+
+func TestExplainSelectorsFile(t *testing.T) {
+	config := &Config{
+		Selectors: []Selector{
+			{CmdSelector: AllowCmds("kubectl delete")},
+			{CmdSelector: AllowCmds("kubectl get")},
+		},
+	}
+
+	root := buildExplainTree(config)
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"mcp", "explain", "--selectors-file", writeSelectorFile(t, "selectors.yaml", `
+selectors:
+  - commands:
+      include: ["kubectl delete"]
+  - commands:
+      include: ["kubectl get"]
+`)})
+	require.NoError(t, root.Execute())
+
+	// Indexes point into Config.Selectors and the file, not the narrowed selectors
+	assert.Regexp(t, `kubectl get\s+exposed\s+kubectl_get\s+selector 1, file selector 1: `, out.String())
+	assert.Regexp(t, `kubectl delete\s+exposed\s+kubectl_delete\s+selector 0, file selector 0: `, out.String())
+}
+
+func TestExplainCommand(t *testing.T) {
+	t.Run("table", func(t *testing.T) {
+		root := buildExplainTree(&Config{Selectors: []Selector{{CmdSelector: AllowCmds("kubectl get")}}})
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs([]string{"mcp", "explain"})
+		require.NoError(t, root.Execute())
+
+		assert.Regexp(t, `COMMAND\s+STATUS\s+TOOL\s+DETAIL`, out.String())
+		assert.Regexp(t, `kubectl get\s+exposed\s+kubectl_get\s+selector 0: cmds=AllowCmds\("kubectl get"\)`, out.String())
+		assert.Regexp(t, `--namespace\s+kept`, out.String())
+		assert.Regexp(t, `--old\s+dropped\s+deprecated`, out.String())
+		assert.Regexp(t, `kubectl delete\s+unmatched\s+-\s+no selector matched`, out.String())
+		assert.Regexp(t, `kubectl secret\s+filtered\s+-\s+hidden`, out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		root := buildExplainTree(nil)
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs([]string{"mcp", "explain", "--output", "json"})
+		require.NoError(t, root.Execute())
+
+		var explanations []commandExplanation
+		require.NoError(t, json.Unmarshal(out.Bytes(), &explanations))
+		require.NotEmpty(t, explanations)
+
+		exposed := 0
+		for _, e := range explanations {
+			if e.Exposed {
+				exposed++
+			}
+		}
+
+		assert.Equal(t, 2, exposed, "get and delete are exposed by default")
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		root := buildExplainTree(nil)
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		root.SetArgs([]string{"mcp", "explain", "-o", "yaml"})
+		err := root.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid output format")
+	})
+}
@@ -1,8 +1,10 @@
 package flags
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/spf13/cobra"
@@ -26,12 +28,12 @@ func isFlagRequired(flag *pflag.Flag) bool {
 	return false
 }
 
+// SchemaAnnotation is the flag annotation holding a JSON schema that is merged into
+// the generated schema of the flag. See mergeSchemaAnnotation.
+const SchemaAnnotation = "jsonschema"
+
 // AddFlagToSchema adds a single flag to the schema properties.
 func AddFlagToSchema(schema *jsonschema.Schema, flag *pflag.Flag) {
-	flagSchema := &jsonschema.Schema{
-		Description: flag.Usage,
-	}
-
 	// Check if flag is marked as required in its annotations
 	// Cobra uses the BashCompOneRequiredFlag annotation to mark required flags
 	if isFlagRequired(flag) {
@@ -43,6 +45,31 @@ func AddFlagToSchema(schema *jsonschema.Schema, flag *pflag.Flag) {
 		schema.Required = append(schema.Required, flag.Name)
 	}
 
+	// The default is derived last, so it matches the type of the merged schema
+	flagSchema := mergeSchemaAnnotation(typeSchema(flag), flag)
+	if flagSchema.Default == nil {
+		setDefaultFromFlag(flagSchema, flag)
+	}
+
+	schema.Properties[flag.Name] = flagSchema
+}
+
+// typeSchema returns the schema for the type of flag.
+// Only custom schemas may carry a default. Custom types describe themselves through the type registry or their value;
+// all other types use the built-in mapping.
+func typeSchema(flag *pflag.Flag) *jsonschema.Schema {
+	flagSchema := &jsonschema.Schema{
+		Description: flag.Usage,
+	}
+
+	if custom := customSchema(flag); custom != nil {
+		if custom.Description == "" {
+			custom.Description = flag.Usage
+		}
+
+		return custom
+	}
+
 	// Set appropriate JSON schema type based on flag type
 	t := flag.Value.Type()
 	switch t {
@@ -53,28 +80,7 @@ func AddFlagToSchema(schema *jsonschema.Schema, flag *pflag.Flag) {
 	case "float32", "float64":
 		flagSchema.Type = "number"
 	case "string":
-		if flag.Annotations != nil {
-			if schemaStrArray, ok := flag.Annotations["jsonschema"]; ok {
-				if len(schemaStrArray) == 0 {
-					slog.Warn(fmt.Sprintf("No value for jsonschema annotation for flag %s, treating as type string", flag.Name))
-					flagSchema.Type = "string"
-				} else {
-					var aSchema jsonschema.Schema
-					err := aSchema.UnmarshalJSON([]byte(schemaStrArray[0]))
-					if err != nil {
-						slog.Error(fmt.Sprintf("Error when decoding JSON schema for flag %s (%v), treating as type string", flag.Name, err))
-						flagSchema.Type = "string"
-					} else {
-						flagSchema = &aSchema
-					}
-				}
-			} else {
-				slog.Debug(fmt.Sprintf("No annotation called jsonschema for flag %s, treating as type string", flag.Name))
-				flagSchema.Type = "string"
-			}
-		} else {
-			flagSchema.Type = "string"
-		}
+		flagSchema.Type = "string"
 
 	case "stringSlice", "stringArray":
 		flagSchema.Type = "array"
@@ -127,6 +133,56 @@ func AddFlagToSchema(schema *jsonschema.Schema, flag *pflag.Flag) {
 		slog.Debug("unknown flag type, defaulting to string", "flag", flag.Name, "type", t)
 	}
 
-	setDefaultFromFlag(flagSchema, flag)
-	schema.Properties[flag.Name] = flagSchema
+	return flagSchema
+}
+
+// mergeSchemaAnnotation overlays the schema in the SchemaAnnotation of flag onto base.
+// Each keyword set in the annotation replaces the same keyword of base, and keywords
+// it does not set are kept, so the description and default survive unless overridden.
+// The merge is shallow: an annotated "items" replaces the generated "items" as a whole.
+// Invalid annotations are logged and ignored.
+func mergeSchemaAnnotation(base *jsonschema.Schema, flag *pflag.Flag) *jsonschema.Schema {
+	values, ok := flag.Annotations[SchemaAnnotation]
+	if !ok {
+		return base
+	}
+
+	if len(values) == 0 {
+		slog.Warn("no value for jsonschema annotation, ignoring", "flag", flag.Name)
+		return base
+	}
+
+	var overlay map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(values[0]), &overlay); err != nil {
+		slog.Error("invalid jsonschema annotation, ignoring", "flag", flag.Name, "error", err)
+		return base
+	}
+
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		slog.Error("failed to encode flag schema, ignoring jsonschema annotation", "flag", flag.Name, "error", err)
+		return base
+	}
+
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(baseJSON, &merged); err != nil {
+		slog.Error("failed to decode flag schema, ignoring jsonschema annotation", "flag", flag.Name, "error", err)
+		return base
+	}
+
+	maps.Copy(merged, overlay)
+
+	mergedJSON, err := json.Marshal(merged)
+	if err != nil {
+		slog.Error("failed to encode merged flag schema, ignoring jsonschema annotation", "flag", flag.Name, "error", err)
+		return base
+	}
+
+	var result jsonschema.Schema
+	if err := result.UnmarshalJSON(mergedJSON); err != nil {
+		slog.Error("invalid jsonschema annotation, ignoring", "flag", flag.Name, "error", err)
+		return base
+	}
+
+	return &result
 }
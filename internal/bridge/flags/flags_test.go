@@ -605,3 +605,94 @@ func TestSetDefaultFromFlag_ScalarTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		define     func(fs *pflag.FlagSet)
+		annotation string
+		check      func(t *testing.T, s *jsonschema.Schema)
+	}{
+		{
+			name:       "integer range keeps description and default",
+			define:     func(fs *pflag.FlagSet) { fs.Int("flag", 3, "Replicas") },
+			annotation: `{"minimum": 1, "maximum": 10}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "integer", s.Type)
+				assert.Equal(t, 1.0, *s.Minimum)
+				assert.Equal(t, 10.0, *s.Maximum)
+				assert.Equal(t, "Replicas", s.Description)
+				assert.JSONEq(t, `3`, string(s.Default))
+			},
+		},
+		{
+			name:       "array max items",
+			define:     func(fs *pflag.FlagSet) { fs.StringSlice("flag", nil, "Tags") },
+			annotation: `{"maxItems": 2}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "array", s.Type)
+				assert.Equal(t, "string", s.Items.Type)
+				assert.Equal(t, 2, *s.MaxItems)
+			},
+		},
+		{
+			name:       "map properties",
+			define:     func(fs *pflag.FlagSet) { fs.StringToString("flag", nil, "Labels") },
+			annotation: `{"properties": {"app": {"type": "string"}}, "required": ["app"]}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "object", s.Type)
+				assert.Equal(t, "string", s.AdditionalProperties.Type)
+				assert.Contains(t, s.Properties, "app")
+				assert.Equal(t, []string{"app"}, s.Required)
+			},
+		},
+		{
+			name:       "duration pattern and description overridden",
+			define:     func(fs *pflag.FlagSet) { fs.Duration("flag", 0, "Timeout") },
+			annotation: `{"pattern": "^[0-9]+s$", "description": "Timeout in seconds"}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "^[0-9]+s$", s.Pattern)
+				assert.Equal(t, "Timeout in seconds", s.Description)
+			},
+		},
+		{
+			name:       "string replaced by object",
+			define:     func(fs *pflag.FlagSet) { fs.String("flag", "", "Config") },
+			annotation: `{"type": "object", "properties": {"a": {"type": "integer"}}}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "object", s.Type)
+				assert.Equal(t, "Config", s.Description)
+			},
+		},
+		{
+			name:       "default overridden",
+			define:     func(fs *pflag.FlagSet) { fs.String("flag", "table", "Output") },
+			annotation: `{"default": "json", "enum": ["json", "table"]}`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.JSONEq(t, `"json"`, string(s.Default))
+				assert.Equal(t, []any{"json", "table"}, s.Enum)
+			},
+		},
+		{
+			name:       "invalid annotation ignored",
+			define:     func(fs *pflag.FlagSet) { fs.Int("flag", 0, "Count") },
+			annotation: `{not json`,
+			check: func(t *testing.T, s *jsonschema.Schema) {
+				assert.Equal(t, "integer", s.Type)
+				assert.Equal(t, "Count", s.Description)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			tt.define(fs)
+			require.NoError(t, fs.SetAnnotation("flag", SchemaAnnotation, []string{tt.annotation}))
+
+			schema := &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{}}
+			AddFlagToSchema(schema, fs.Lookup("flag"))
+			tt.check(t, schema.Properties["flag"])
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package flags
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/pflag"
+)
+
+// Flag group annotations set by cobra's MarkFlagsRequiredTogether, MarkFlagsOneRequired
+// and MarkFlagsMutuallyExclusive. Cobra does not export these keys.
+const (
+	requiredTogetherAnnotation  = "cobra_annotation_required_if_others_set"
+	oneRequiredAnnotation       = "cobra_annotation_one_required"
+	mutuallyExclusiveAnnotation = "cobra_annotation_mutually_exclusive"
+)
+
+// AddFlagGroupsToSchema translates the cobra flag groups of fs into constraints on schema:
+//   - required together: dependentRequired, so each flag requires the others
+//   - one required: anyOf, so at least one flag is set
+//   - mutually exclusive: oneOf over each flag and "none of them", so at most one flag is set
+//
+// Cobra checks groups against the flags that were changed on the command line. A false bool
+// flag that defaults to false is left off the command line, so it does not count as set:
+// groups with such flags match "set" with a value other than false instead of presence,
+// and required-together groups use anyOf "all set" or "none set" instead of dependentRequired.
+//
+// Only flags already in schema.Properties are considered, so flags that were filtered out
+// never become required. Groups with fewer than two exposed flags (one for one-required)
+// are skipped. Like cobra, a group is ignored unless all of its flags are defined in fs.
+// Each allOf entry has a description that explains the group in validation errors.
+func AddFlagGroupsToSchema(schema *jsonschema.Schema, fs *pflag.FlagSet) {
+	for _, group := range flagGroups(fs, requiredTogetherAnnotation) {
+		names := exposedFlags(schema, fs, group, requiredTogetherAnnotation)
+		if len(names) < 2 {
+			continue
+		}
+
+		if slices.ContainsFunc(names, func(name string) bool { return omitsFalse(fs.Lookup(name)) }) {
+			schema.AllOf = append(schema.AllOf, &jsonschema.Schema{
+				Description: fmt.Sprintf("the flags %s must be set together", strings.Join(names, ", ")),
+				AnyOf: []*jsonschema.Schema{
+					{AllOf: setEach(fs, names)},
+					{Not: &jsonschema.Schema{AnyOf: setEach(fs, names)}},
+				},
+			})
+			continue
+		}
+
+		if schema.DependentRequired == nil {
+			schema.DependentRequired = make(map[string][]string)
+		}
+
+		for _, name := range names {
+			for _, other := range names {
+				if other != name && !slices.Contains(schema.DependentRequired[name], other) {
+					schema.DependentRequired[name] = append(schema.DependentRequired[name], other)
+				}
+			}
+		}
+	}
+
+	for _, group := range flagGroups(fs, oneRequiredAnnotation) {
+		names := exposedFlags(schema, fs, group, oneRequiredAnnotation)
+		if len(names) == 0 {
+			continue
+		}
+
+		schema.AllOf = append(schema.AllOf, &jsonschema.Schema{
+			Description: fmt.Sprintf("at least one of the flags %s must be set", strings.Join(names, ", ")),
+			AnyOf:       setEach(fs, names),
+		})
+	}
+
+	for _, group := range flagGroups(fs, mutuallyExclusiveAnnotation) {
+		names := exposedFlags(schema, fs, group, mutuallyExclusiveAnnotation)
+		if len(names) < 2 {
+			continue
+		}
+
+		// Exactly one branch matches when at most one flag is set
+		none := &jsonschema.Schema{Not: &jsonschema.Schema{AnyOf: setEach(fs, names)}}
+		schema.AllOf = append(schema.AllOf, &jsonschema.Schema{
+			Description: fmt.Sprintf("at most one of the flags %s may be set", strings.Join(names, ", ")),
+			OneOf:       append(setEach(fs, names), none),
+		})
+	}
+}
+
+// flagGroups returns the distinct groups stored under annotation on the flags of fs,
+// each as its list of flag names, in the order they are first seen.
+func flagGroups(fs *pflag.FlagSet, annotation string) [][]string {
+	var (
+		seen   = make(map[string]bool)
+		groups [][]string
+	)
+
+	fs.VisitAll(func(flag *pflag.Flag) {
+		for _, group := range flag.Annotations[annotation] {
+			if seen[group] {
+				continue
+			}
+
+			seen[group] = true
+			groups = append(groups, strings.Split(group, " "))
+		}
+	})
+
+	return groups
+}
+
+// exposedFlags returns the flags of group that are present in schema.Properties.
+// It returns nil if any flag of the group is not defined in fs.
+func exposedFlags(schema *jsonschema.Schema, fs *pflag.FlagSet, group []string, annotation string) []string {
+	var names []string
+	for _, name := range group {
+		if fs.Lookup(name) == nil {
+			return nil
+		}
+
+		if _, ok := schema.Properties[name]; ok {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) < len(group) {
+		slog.Debug("flag group has flags that are not exposed, constraining exposed flags only", "group", group, "annotation", annotation)
+	}
+
+	return names
+}
+
+// setEach returns one schema per name that requires that flag to be set.
+// A false bool flag that defaults to false does not count as set.
+func setEach(fs *pflag.FlagSet, names []string) []*jsonschema.Schema {
+	schemas := make([]*jsonschema.Schema, 0, len(names))
+	for _, name := range names {
+		set := &jsonschema.Schema{Required: []string{name}}
+		if omitsFalse(fs.Lookup(name)) {
+			var f any = false
+			set.Properties = map[string]*jsonschema.Schema{name: {Not: &jsonschema.Schema{Const: &f}}}
+		}
+
+		schemas = append(schemas, set)
+	}
+
+	return schemas
+}
+
+// omitsFalse reports whether flag is a bool flag that defaults to false. A false value
+// for it is left off the command line, so cobra does not see the flag as changed.
+func omitsFalse(flag *pflag.Flag) bool {
+	return flag.Value.Type() == "bool" && flag.DefValue != "true"
+}
@@ -0,0 +1,124 @@
+package flags
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddFlagGroupsToSchema(t *testing.T) {
+	cmd := &cobra.Command{Use: "deploy"}
+	cmd.Flags().String("user", "", "User")
+	cmd.Flags().String("password", "", "Password")
+	cmd.Flags().Bool("json", false, "JSON output")
+	cmd.Flags().Bool("yaml", false, "YAML output")
+	cmd.Flags().Bool("table", false, "Table output")
+	cmd.Flags().String("file", "", "Manifest file")
+	cmd.Flags().String("url", "", "Manifest URL")
+	cmd.Flags().String("secret", "", "Not exposed")
+	cmd.MarkFlagsRequiredTogether("user", "password")
+	cmd.MarkFlagsMutuallyExclusive("json", "yaml", "table")
+	cmd.MarkFlagsOneRequired("file", "url")
+	cmd.MarkFlagsMutuallyExclusive("file", "url")
+	cmd.MarkFlagsMutuallyExclusive("json", "secret")
+
+	schema := &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{}}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "secret" {
+			AddFlagToSchema(schema, flag)
+		}
+	})
+	AddFlagGroupsToSchema(schema, cmd.Flags())
+
+	assert.Equal(t, map[string][]string{"user": {"password"}, "password": {"user"}}, schema.DependentRequired)
+	// one-required plus two mutually exclusive groups; the group with "secret" has one exposed flag
+	assert.Len(t, schema.AllOf, 3)
+
+	resolved, err := schema.Resolve(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		flags map[string]any
+		valid bool
+	}{
+		{"one source", map[string]any{"file": "a.yaml"}, true},
+		{"no source", map[string]any{}, false},
+		{"both sources", map[string]any{"file": "a.yaml", "url": "http://x"}, false},
+		{"credentials together", map[string]any{"url": "http://x", "user": "me", "password": "pw"}, true},
+		{"user without password", map[string]any{"url": "http://x", "user": "me"}, false},
+		{"one format", map[string]any{"url": "http://x", "yaml": true}, true},
+		{"two formats", map[string]any{"url": "http://x", "json": true, "table": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolved.Validate(tt.flags)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAddFlagGroupsToSchemaFalseBools(t *testing.T) {
+	// False bools that default to false are left off the command line, so cobra
+	// does not count them as set
+	tests := []struct {
+		name  string
+		mark  func(cmd *cobra.Command)
+		flags map[string]any
+		valid bool
+	}{
+		{"exclusive with false bool", func(cmd *cobra.Command) { cmd.MarkFlagsMutuallyExclusive("all", "selector") }, map[string]any{"all": false, "selector": "x"}, true},
+		{"exclusive with true bool", func(cmd *cobra.Command) { cmd.MarkFlagsMutuallyExclusive("all", "selector") }, map[string]any{"all": true, "selector": "x"}, false},
+		{"exclusive with false default-true bool", func(cmd *cobra.Command) { cmd.MarkFlagsMutuallyExclusive("color", "selector") }, map[string]any{"color": false, "selector": "x"}, false},
+		{"one required by false bool", func(cmd *cobra.Command) { cmd.MarkFlagsOneRequired("all", "selector") }, map[string]any{"all": false}, false},
+		{"one required by true bool", func(cmd *cobra.Command) { cmd.MarkFlagsOneRequired("all", "selector") }, map[string]any{"all": true}, true},
+		{"together with false bool", func(cmd *cobra.Command) { cmd.MarkFlagsRequiredTogether("all", "selector") }, map[string]any{"all": false, "selector": "x"}, false},
+		{"together with true bool", func(cmd *cobra.Command) { cmd.MarkFlagsRequiredTogether("all", "selector") }, map[string]any{"all": true, "selector": "x"}, true},
+		{"together with neither", func(cmd *cobra.Command) { cmd.MarkFlagsRequiredTogether("all", "selector") }, map[string]any{"all": false}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "delete"}
+			cmd.Flags().Bool("all", false, "All")
+			cmd.Flags().Bool("color", true, "Color")
+			cmd.Flags().String("selector", "", "Selector")
+			tt.mark(cmd)
+
+			schema := &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{}}
+			cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+				AddFlagToSchema(schema, flag)
+			})
+			AddFlagGroupsToSchema(schema, cmd.Flags())
+
+			resolved, err := schema.Resolve(nil)
+			require.NoError(t, err)
+			if err := resolved.Validate(tt.flags); tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAddFlagGroupsToSchemaUndefinedFlag(t *testing.T) {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("a", "", "")
+	require.NoError(t, fs.SetAnnotation("a", mutuallyExclusiveAnnotation, []string{"a b"}))
+
+	schema := &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"a": {Type: "string"}}}
+	AddFlagGroupsToSchema(schema, fs)
+
+	// cobra ignores groups whose flags are not all defined
+	assert.Empty(t, schema.AllOf)
+}
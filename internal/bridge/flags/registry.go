@@ -0,0 +1,61 @@
+package flags
+
+import (
+	"sync"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/pflag"
+)
+
+// SchemaFunc returns the JSON schema for a flag, or nil to fall back to the built-in mapping.
+type SchemaFunc func(flag *pflag.Flag) *jsonschema.Schema
+
+// Schemer is implemented by pflag.Value types that describe their own JSON schema.
+type Schemer interface {
+	JSONSchema() *jsonschema.Schema
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]SchemaFunc)
+)
+
+// RegisterType registers fn as the schema mapping for flags whose Value.Type() is typeName.
+// A later registration for the same type replaces the earlier one; a nil fn removes it.
+func RegisterType(typeName string, fn SchemaFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if fn == nil {
+		delete(registry, typeName)
+		return
+	}
+
+	registry[typeName] = fn
+}
+
+// customSchema returns the schema for flag from the type registry or from the flag value's
+// JSONSchema method, in that order. It returns nil if neither supplies one.
+// The schema is copied, so callers may modify it.
+func customSchema(flag *pflag.Flag) *jsonschema.Schema {
+	registryMu.RLock()
+	fn := registry[flag.Value.Type()]
+	registryMu.RUnlock()
+
+	var schema *jsonschema.Schema
+	if fn != nil {
+		schema = fn(flag)
+	}
+
+	if schema == nil {
+		if schemer, ok := flag.Value.(Schemer); ok {
+			schema = schemer.JSONSchema()
+		}
+	}
+
+	if schema == nil {
+		return nil
+	}
+
+	return schema.CloneSchemas()
+}
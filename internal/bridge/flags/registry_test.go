@@ -0,0 +1,71 @@
+package flags
+
+import (
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// semverValue is a custom flag type without a schema of its own.
+type semverValue string
+
+func (v *semverValue) String() string     { return string(*v) }
+func (v *semverValue) Set(s string) error { *v = semverValue(s); return nil }
+func (v *semverValue) Type() string       { return "semver" }
+
+// levelValue is a custom flag type that describes itself.
+type levelValue string
+
+func (v *levelValue) String() string     { return string(*v) }
+func (v *levelValue) Set(s string) error { *v = levelValue(s); return nil }
+func (v *levelValue) Type() string       { return "level" }
+func (v *levelValue) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{Type: "string", Enum: []any{"debug", "info", "error"}}
+}
+
+func TestCustomFlagTypes(t *testing.T) {
+	shared := &jsonschema.Schema{Type: "string", Pattern: `^v\d+\.\d+\.\d+$`}
+	RegisterType("semver", func(*pflag.Flag) *jsonschema.Schema { return shared })
+	t.Cleanup(func() { RegisterType("semver", nil) })
+
+	version := semverValue("v1.0.0")
+	level := levelValue("info")
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.Var(&version, "version", "Release version")
+	fs.Var(&level, "level", "Log level")
+
+	schema := &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{}}
+	fs.VisitAll(func(flag *pflag.Flag) { AddFlagToSchema(schema, flag) })
+
+	// Registered types get the registered schema, with usage and default filled in
+	versionSchema := schema.Properties["version"]
+	assert.Equal(t, shared.Pattern, versionSchema.Pattern)
+	assert.Equal(t, "Release version", versionSchema.Description)
+	assert.JSONEq(t, `"v1.0.0"`, string(versionSchema.Default))
+	assert.Empty(t, shared.Description, "registered schema must not be modified")
+
+	// Values that implement JSONSchema describe themselves
+	levelSchema := schema.Properties["level"]
+	assert.Equal(t, []any{"debug", "info", "error"}, levelSchema.Enum)
+	assert.Equal(t, "Log level", levelSchema.Description)
+
+	// The registry takes precedence over the value, and nil falls back
+	RegisterType("level", func(*pflag.Flag) *jsonschema.Schema { return &jsonschema.Schema{Type: "integer"} })
+	t.Cleanup(func() { RegisterType("level", nil) })
+	AddFlagToSchema(schema, fs.Lookup("level"))
+	assert.Equal(t, "integer", schema.Properties["level"].Type)
+
+	RegisterType("level", func(*pflag.Flag) *jsonschema.Schema { return nil })
+	AddFlagToSchema(schema, fs.Lookup("level"))
+	assert.Equal(t, []any{"debug", "info", "error"}, schema.Properties["level"].Enum)
+
+	// Unregistered types still fall back to strings
+	RegisterType("semver", nil)
+	AddFlagToSchema(schema, fs.Lookup("version"))
+	require.NotNil(t, schema.Properties["version"])
+	assert.Equal(t, "string", schema.Properties["version"].Type)
+	assert.Empty(t, schema.Properties["version"].Pattern)
+}
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -29,6 +30,11 @@ type Cache struct {
 	data []byte
 }
 
+// JSON returns the cached schema as JSON.
+func (s *Cache) JSON() []byte {
+	return bytes.Clone(s.data)
+}
+
 // Copy returns a copy of the cached schema.
 func (s *Cache) Copy() *jsonschema.Schema {
 	schema := &jsonschema.Schema{}
@@ -0,0 +1,142 @@
+package ophis
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// truncatingWriter keeps the head and tail of everything written to it, within byte and line caps.
+// Output between the head and the tail is counted and discarded, so the writer never
+// blocks the command. Memory is bounded by the byte cap; the line cap alone does not
+// bound it, since a single line, such as minified JSON, can be of any length.
+// A zero cap is unlimited; with both caps zero the writer keeps everything.
+type truncatingWriter struct {
+	headBytes, headLines int // -1 means unlimited
+	tailBytes, tailLines int // -1 means unlimited
+
+	head      []byte
+	tail      []byte
+	headLineN int // newlines in head
+	headFull  bool
+	total     int // bytes written
+}
+
+// newTruncatingWriter creates a truncatingWriter that splits each cap evenly between head and tail.
+func newTruncatingWriter(maxBytes, maxLines int) *truncatingWriter {
+	w := &truncatingWriter{headBytes: -1, headLines: -1, tailBytes: -1, tailLines: -1}
+	if maxBytes > 0 {
+		w.headBytes = maxBytes / 2
+		w.tailBytes = maxBytes - w.headBytes
+	}
+
+	if maxLines > 0 {
+		w.headLines = maxLines / 2
+		w.tailLines = maxLines - w.headLines
+	}
+
+	return w
+}
+
+// Write implements io.Writer. It always consumes all of p.
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	w.total += n
+
+	if !w.headFull {
+		room := w.headRoom(p)
+		w.head = append(w.head, p[:room]...)
+		w.headLineN += bytes.Count(p[:room], []byte{'\n'})
+		p = p[room:]
+		if len(p) == 0 {
+			return n, nil
+		}
+
+		w.headFull = true
+	}
+
+	w.tail = append(w.tail, p...)
+	w.trimTail()
+	return n, nil
+}
+
+// headRoom returns how many leading bytes of p still fit in the head.
+func (w *truncatingWriter) headRoom(p []byte) int {
+	room := len(p)
+	if w.headBytes >= 0 {
+		room = min(room, w.headBytes-len(w.head))
+	}
+
+	if w.headLines >= 0 {
+		lines := w.headLineN
+		for i, b := range p[:room] {
+			if lines >= w.headLines {
+				return i
+			}
+
+			if b == '\n' {
+				lines++
+			}
+		}
+	}
+
+	return room
+}
+
+// trimTail drops the oldest tail bytes that exceed the tail caps.
+func (w *truncatingWriter) trimTail() {
+	cut := 0
+	if w.tailBytes >= 0 && len(w.tail) > w.tailBytes {
+		cut = len(w.tail) - w.tailBytes
+	}
+
+	if w.tailLines >= 0 {
+		cut = max(cut, lastLinesStart(w.tail, w.tailLines))
+	}
+
+	if cut > 0 {
+		w.tail = append(w.tail[:0], w.tail[cut:]...)
+	}
+}
+
+// lastLinesStart returns the index where the last n lines of b begin.
+// A trailing newline does not start a new line.
+func lastLinesStart(b []byte, n int) int {
+	end := len(b)
+	if end > 0 && b[end-1] == '\n' {
+		end--
+	}
+
+	if n == 0 {
+		return len(b)
+	}
+
+	for i := end - 1; i >= 0; i-- {
+		if b[i] == '\n' {
+			n--
+			if n == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+// omitted returns the number of bytes that were discarded.
+func (w *truncatingWriter) omitted() int {
+	return w.total - len(w.head) - len(w.tail)
+}
+
+// Truncated reports whether any output was discarded.
+func (w *truncatingWriter) Truncated() bool {
+	return w.omitted() > 0
+}
+
+// String returns the kept output, with a marker where output was discarded.
+func (w *truncatingWriter) String() string {
+	if !w.Truncated() {
+		return string(w.head) + string(w.tail)
+	}
+
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", w.head, w.omitted(), w.tail)
+}
@@ -0,0 +1,87 @@
+package ophis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncatingWriter(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxBytes  int
+		maxLines  int
+		writes    []string
+		expected  string
+		truncated bool
+	}{
+		{
+			name:     "no limits keeps everything",
+			writes:   []string{"hello ", "world\n"},
+			expected: "hello world\n",
+		},
+		{
+			name:     "under byte limit",
+			maxBytes: 100,
+			writes:   []string{"hello ", "world\n"},
+			expected: "hello world\n",
+		},
+		{
+			name:     "exactly at byte limit",
+			maxBytes: 4,
+			writes:   []string{"ab", "cd"},
+			expected: "abcd",
+		},
+		{
+			name:      "byte limit keeps head and tail",
+			maxBytes:  4,
+			writes:    []string{"abc", "def", "ghi"},
+			expected:  "ab\n... [5 bytes truncated] ...\nhi",
+			truncated: true,
+		},
+		{
+			name:      "line limit keeps head and tail lines",
+			maxLines:  2,
+			writes:    []string{"1\n2\n", "3\n4\n", "5\n"},
+			expected:  "1\n\n... [6 bytes truncated] ...\n5\n",
+			truncated: true,
+		},
+		{
+			name:      "odd line limit favors the tail",
+			maxLines:  3,
+			writes:    []string{"1\n2\n3\n4\n5\n"},
+			expected:  "1\n\n... [4 bytes truncated] ...\n4\n5\n",
+			truncated: true,
+		},
+		{
+			name:      "single line limit keeps only the tail",
+			maxLines:  1,
+			writes:    []string{"1\n2\n3"},
+			expected:  "\n... [4 bytes truncated] ...\n3",
+			truncated: true,
+		},
+		{
+			name:      "both limits apply",
+			maxBytes:  6,
+			maxLines:  10,
+			writes:    []string{strings.Repeat("x", 10)},
+			expected:  "xxx\n... [4 bytes truncated] ...\nxxx",
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTruncatingWriter(tt.maxBytes, tt.maxLines)
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				assert.NoError(t, err)
+				assert.Equal(t, len(s), n, "writer must consume all input")
+			}
+
+			assert.Equal(t, tt.expected, w.String())
+			assert.Equal(t, tt.truncated, w.Truncated())
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package ophis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxProgressLineBytes caps the message of a single progress notification.
+// Longer lines are truncated, so output without newlines cannot grow the line buffer.
+const maxProgressLineBytes = 4096
+
+// progressQueueSize is how many progress notifications may wait to be sent.
+// Notifications beyond it are dropped, so a slow client never blocks the command's output.
+const progressQueueSize = 256
+
+// progressTruncatedMessage is the last notification sent once the output limits are reached.
+const progressTruncatedMessage = "... [progress output truncated]"
+
+// progressNotifier turns command output into MCP progress notifications, one per line.
+// It is shared by the stdout and stderr writers of a single tool call, and the output
+// limits apply to the messages of both together.
+type progressNotifier struct {
+	mu       sync.Mutex
+	lines    int
+	bytes    int
+	maxBytes int  // zero means unlimited
+	maxLines int  // zero means unlimited
+	stopped  bool // the limits were reached or the notifier was closed
+
+	queue  chan progressMessage // nil sends synchronously
+	done   chan struct{}        // closed once the queue is drained
+	notify func(line string, progress float64)
+}
+
+// progressMessage is a queued progress notification.
+type progressMessage struct {
+	line     string
+	progress float64
+}
+
+// newProgressNotifier returns a notifier that reports to the client that sent request,
+// or nil if the request carries no progress token. Notifications are sent from a separate
+// goroutine until close is called.
+func newProgressNotifier(ctx context.Context, request *mcp.CallToolRequest, maxBytes, maxLines int) *progressNotifier {
+	if request.Session == nil || request.Params == nil {
+		return nil
+	}
+
+	token := request.Params.GetProgressToken()
+	if token == nil {
+		return nil
+	}
+
+	p := &progressNotifier{
+		maxBytes: maxBytes,
+		maxLines: maxLines,
+		queue:    make(chan progressMessage, progressQueueSize),
+		done:     make(chan struct{}),
+		notify: func(line string, progress float64) {
+			err := request.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+				ProgressToken: token,
+				Message:       line,
+				Progress:      progress,
+			})
+			if err != nil {
+				slog.Debug("failed to send progress notification", "error", err)
+			}
+		},
+	}
+
+	go func() {
+		defer close(p.done)
+		for m := range p.queue {
+			p.notify(m.line, m.progress)
+		}
+	}()
+
+	return p
+}
+
+// send reports a single line of output. Once the output limits are reached it sends
+// progressTruncatedMessage, and drops every later line.
+func (p *progressNotifier) send(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	if (p.maxLines > 0 && p.lines >= p.maxLines) || (p.maxBytes > 0 && p.bytes+len(line) > p.maxBytes) {
+		p.stopped = true
+		line = progressTruncatedMessage
+	}
+
+	p.lines++
+	p.bytes += len(line)
+	m := progressMessage{line: line, progress: float64(p.lines)}
+	if p.queue == nil {
+		p.notify(m.line, m.progress)
+		return
+	}
+
+	select {
+	case p.queue <- m:
+	default:
+		slog.Debug("dropping progress notification, client is not keeping up")
+	}
+}
+
+// close stops accepting lines and waits until queued notifications are sent.
+func (p *progressNotifier) close() {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+
+	if p.queue != nil {
+		close(p.queue)
+		<-p.done
+	}
+}
+
+// writer returns a line-buffered writer that sends each complete line to p.
+func (p *progressNotifier) writer() *progressWriter {
+	return &progressWriter{notifier: p}
+}
+
+// progressWriter buffers partial lines until they are complete.
+// Lines longer than maxProgressLineBytes are truncated.
+type progressWriter struct {
+	notifier  *progressNotifier
+	buf       []byte
+	truncated int // bytes dropped from the current line
+}
+
+// Write implements io.Writer.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.appendLine(p)
+			break
+		}
+
+		w.appendLine(p[:i])
+		w.sendLine()
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// appendLine adds b to the current line, dropping what does not fit in maxProgressLineBytes.
+func (w *progressWriter) appendLine(b []byte) {
+	if room := maxProgressLineBytes - len(w.buf); len(b) > room {
+		w.truncated += len(b) - room
+		b = b[:room]
+	}
+
+	w.buf = append(w.buf, b...)
+}
+
+// sendLine sends the current line and starts a new one.
+func (w *progressWriter) sendLine() {
+	line := string(bytes.TrimSuffix(w.buf, []byte{'\r'}))
+	if w.truncated > 0 {
+		line += fmt.Sprintf(" ... [%d bytes truncated]", w.truncated)
+	}
+
+	w.notifier.send(line)
+	w.buf = w.buf[:0]
+	w.truncated = 0
+}
+
+// Flush sends any trailing partial line.
+func (w *progressWriter) Flush() {
+	if len(w.buf) > 0 || w.truncated > 0 {
+		w.sendLine()
+	}
+}
+
+// teeProgress tees stdout and stderr into p.
+// It returns the writers to use and a func to call once the command is done, which flushes
+// trailing partial lines and waits for queued notifications to be sent.
+// If p is nil, the writers are returned unchanged.
+func teeProgress(p *progressNotifier, stdout, stderr io.Writer) (io.Writer, io.Writer, func()) {
+	if p == nil {
+		return stdout, stderr, func() {}
+	}
+
+	outProgress, errProgress := p.writer(), p.writer()
+	flush := func() {
+		outProgress.Flush()
+		errProgress.Flush()
+		p.close()
+	}
+
+	return io.MultiWriter(stdout, outProgress), io.MultiWriter(stderr, errProgress), flush
+}
@@ -0,0 +1,141 @@
+package ophis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var lines []string
+	var progress []float64
+	p := &progressNotifier{notify: func(line string, n float64) {
+		lines = append(lines, line)
+		progress = append(progress, n)
+	}}
+
+	stdout, stderr := p.writer(), p.writer()
+	_, _ = stdout.Write([]byte("step 1\nstep"))
+	_, _ = stderr.Write([]byte("warning\r\n"))
+	_, _ = stdout.Write([]byte(" 2\nstep 3"))
+	stdout.Flush()
+	stderr.Flush()
+
+	assert.Equal(t, []string{"step 1", "warning", "step 2", "step 3"}, lines)
+	assert.Equal(t, []float64{1, 2, 3, 4}, progress)
+}
+
+func TestProgressWriterLongLine(t *testing.T) {
+	var lines []string
+	p := &progressNotifier{notify: func(line string, _ float64) {
+		lines = append(lines, line)
+	}}
+
+	w := p.writer()
+	chunk := []byte(strings.Repeat("x", 1000))
+	for range 10 {
+		_, _ = w.Write(chunk)
+	}
+
+	// The buffer stops growing at the line cap
+	assert.Len(t, w.buf, maxProgressLineBytes)
+	assert.Empty(t, lines)
+
+	_, _ = w.Write([]byte("\nnext"))
+	w.Flush()
+
+	require.Len(t, lines, 2)
+	assert.Equal(t, strings.Repeat("x", maxProgressLineBytes)+" ... [5904 bytes truncated]", lines[0])
+	assert.Equal(t, "next", lines[1])
+}
+
+func TestProgressLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		maxLines int
+		expected []string
+	}{
+		{"unlimited", 0, 0, []string{"one", "two", "three"}},
+		{"lines", 0, 2, []string{"one", "two", progressTruncatedMessage}},
+		{"bytes", 5, 0, []string{"one", progressTruncatedMessage}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			p := &progressNotifier{maxBytes: tt.maxBytes, maxLines: tt.maxLines, notify: func(line string, _ float64) {
+				lines = append(lines, line)
+			}}
+
+			w := p.writer()
+			_, _ = w.Write([]byte("one\ntwo\nthree\n"))
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestProgressNotifications(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	root.AddCommand(&cobra.Command{
+		Use: "build",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Println("compiling")
+			cmd.Println("linking")
+			cmd.PrintErrln("done")
+		},
+	})
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	client := mcp.NewClient(&mcp.Implementation{Name: "client"}, &mcp.ClientOptions{
+		ProgressNotificationHandler: func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			messages = append(messages, req.Params.Message)
+		},
+	})
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := c.server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = serverSession.Close() }()
+
+	session, err := client.Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	params := &mcp.CallToolParams{
+		Meta:      mcp.Meta{},
+		Name:      "root_build",
+		Arguments: map[string]any{"flags": map[string]any{}},
+	}
+	params.SetProgressToken("build-1")
+	res, err := session.CallTool(ctx, params)
+	require.NoError(t, err)
+	assert.False(t, res.IsError)
+
+	// Notifications are delivered asynchronously
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(messages) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []string{"compiling", "linking", "done"}, messages)
+}
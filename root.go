@@ -27,6 +27,7 @@ func Command(config *Config) *cobra.Command {
 	cmd.AddCommand(
 		startCommand(config),
 		toolCommand(config),
+		explainCommand(config),
 		streamCommand(config),
 		claude.Command(name, defaultEnv),
 		vscode.Command(name, defaultEnv),
@@ -1,6 +1,15 @@
 package ophis
 
-import "github.com/njayp/ophis/internal/schema"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/njayp/ophis/internal/bridge/flags"
+	"github.com/njayp/ophis/internal/schema"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
 
 // ToolInput represents the input structure for command tools.
 // Do not `omitempty` the Flags field, there may be required flags inside.
@@ -11,12 +20,90 @@ type ToolInput struct {
 
 // ToolOutput represents the output structure for command tools.
 type ToolOutput struct {
-	StdOut   string `json:"stdout,omitempty" jsonschema:"Standard output"`
-	StdErr   string `json:"stderr,omitempty" jsonschema:"Standard error"`
-	ExitCode int    `json:"exitCode" jsonschema:"Exit code"`
+	StdOut    string `json:"stdout,omitempty" jsonschema:"Standard output"`
+	StdErr    string `json:"stderr,omitempty" jsonschema:"Standard error"`
+	ExitCode  int    `json:"exitCode" jsonschema:"Exit code"`
+	TimedOut  bool   `json:"timedOut,omitempty" jsonschema:"True if the command was stopped for exceeding its timeout"`
+	Result    any    `json:"result,omitempty" jsonschema:"Standard output parsed as JSON, for commands with JSON output enabled"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"True if stdout or stderr was truncated to fit the output limits"`
+
+	ValidationIssues []ValidationIssue `json:"validationIssues,omitempty" jsonschema:"Problems with the arguments of a call that was rejected before the command ran"`
 }
 
 var (
 	inputSchema  = schema.New[ToolInput]()
 	outputSchema = schema.New[ToolOutput]()
 )
+
+// WithOutputType declares that cmd prints a JSON value of type T to stdout.
+// It enables JSON output for cmd and sets the schema of the result field to the
+// schema generated for T, so agents get typed results. Output that does not match
+// the schema at call time is returned as text with a tool error.
+// It returns cmd for chaining.
+//
+// Example:
+//
+//	cmd := ophis.WithOutputType[[]Pod](&cobra.Command{Use: "pods", ...})
+func WithOutputType[T any](cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+
+	cmd.Annotations[AnnotationOutput] = OutputFormatJSON
+	cmd.Annotations[AnnotationOutputSchema] = string(schema.New[T]().JSON())
+	return cmd
+}
+
+// SetFlagSchema merges schema into the generated schema of the flag called name on cmd,
+// by setting its FlagAnnotationSchema annotation. Keywords set in schema replace the
+// generated ones; the flag's description and default are kept unless schema sets them.
+// Local flags are looked up first, then persistent flags.
+//
+// Example:
+//
+//	err := ophis.SetFlagSchema(cmd, "replicas", &jsonschema.Schema{
+//		Minimum: jsonschema.Ptr(1.0),
+//		Maximum: jsonschema.Ptr(10.0),
+//	})
+func SetFlagSchema(cmd *cobra.Command, name string, schema *jsonschema.Schema) error {
+	fs := cmd.Flags()
+	if fs.Lookup(name) == nil {
+		fs = cmd.PersistentFlags()
+	}
+
+	if fs.Lookup(name) == nil {
+		return fmt.Errorf("flag %q not found on command %q", name, cmd.CommandPath())
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("encoding schema for flag %q: %w", name, err)
+	}
+
+	return fs.SetAnnotation(name, FlagAnnotationSchema, []string{string(data)})
+}
+
+// FlagSchemaFunc returns the JSON schema for a flag, or nil to use the built-in mapping.
+type FlagSchemaFunc func(flag *pflag.Flag) *jsonschema.Schema
+
+// FlagSchemer can be implemented by custom pflag.Value types to supply their own JSON schema.
+// It is used for flags whose type has no FlagSchemaFunc registered.
+type FlagSchemer interface {
+	JSONSchema() *jsonschema.Schema
+}
+
+// RegisterFlagType sets the schema of every flag whose Value.Type() is typeName,
+// for custom pflag.Value types that the built-in mapping would describe as plain strings.
+// Registered types take precedence over the built-in mapping and over FlagSchemer.
+// If the schema has no description, the flag usage is used; if it has no default,
+// one is derived from the flag's default value.
+// Register types before the MCP commands are run. A nil fn removes the registration.
+//
+// Example:
+//
+//	ophis.RegisterFlagType("semver", func(*pflag.Flag) *jsonschema.Schema {
+//		return &jsonschema.Schema{Type: "string", Pattern: `^v?\d+\.\d+\.\d+$`}
+//	})
+func RegisterFlagType(typeName string, fn FlagSchemaFunc) {
+	flags.RegisterType(typeName, flags.SchemaFunc(fn))
+}
@@ -1,9 +1,12 @@
 package ophis
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
 	"strings"
+	"time"
 
 	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -12,6 +15,9 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// maxToolNameLength is the longest tool name allowed by MCP.
+const maxToolNameLength = 128
+
 // CmdSelector determines if a command should become an MCP tool.
 // Return true to include the command as a tool.
 // Note: Basic safety filters (hidden, deprecated, non-runnable) are always applied first.
@@ -65,6 +71,87 @@ type Selector struct {
 	// Common uses: error handling, response filtering, metrics collection.
 	// If nil, no middleware is applied.
 	Middleware MiddlewareFunc
+
+	// Timeout limits how long tool calls for commands matched by CmdSelector may run.
+	// If zero, Config.Timeout is used.
+	Timeout time.Duration
+
+	// MaxOutputBytes caps the size of each output stream for commands matched by CmdSelector.
+	// If zero, Config.MaxOutputBytes is used.
+	MaxOutputBytes int
+
+	// MaxOutputLines caps the number of lines of each output stream for commands matched by CmdSelector.
+	// If zero, Config.MaxOutputLines is used.
+	MaxOutputLines int
+
+	// DisableProgress stops commands matched by CmdSelector from streaming their output
+	// as MCP progress notifications. By default, when a tool call carries a progress token,
+	// every line of stdout and stderr is sent to the client as it is produced.
+	DisableProgress bool
+
+	// JSONOutput parses the stdout of commands matched by CmdSelector as JSON and returns it
+	// in the result field of the tool output instead of as text.
+	// Output that is not valid JSON falls back to text.
+	// Commands can opt in individually with the AnnotationOutput command annotation.
+	JSONOutput bool
+
+	// EnrichDescription appends the usage line, aliases, SuggestFor names, valid
+	// arguments, sibling commands, and parent command descriptions to the descriptions
+	// of tools for commands matched by CmdSelector.
+	EnrichDescription bool
+
+	// DescriptionTemplate is a text/template that renders the descriptions of tools for
+	// commands matched by CmdSelector. It is executed with a DescriptionData, and can use
+	// the functions join (strings.Join) and truncate (truncate N TEXT).
+	// If the template fails, the default description is used.
+	//
+	// Example:
+	//
+	//	{{.Short}}
+	//	Usage: {{.UseLine}}{{if .Aliases}}
+	//	Aliases: {{join .Aliases ", "}}{{end}}
+	DescriptionTemplate string
+
+	// DescriptionFunc returns the descriptions of tools for commands matched by CmdSelector.
+	// It takes precedence over DescriptionTemplate and EnrichDescription.
+	DescriptionFunc func(data DescriptionData) string
+
+	// Annotations overrides the MCP tool annotation keys (AnnotationTitle, AnnotationReadOnly,
+	// AnnotationDestructive, AnnotationIdempotent, AnnotationOpenWorld) of commands matched
+	// by CmdSelector. Keys it leaves out are read from the command's own annotations.
+	Annotations map[string]string
+
+	// Overrides replaces the generated name, title, or description of the tools for the
+	// listed commands, keyed by command path (e.g. "kubectl get pods"). It takes precedence
+	// over the AnnotationName and AnnotationDescription command annotations.
+	// Tool names must be unique: registration fails if two commands get the same name.
+	Overrides map[string]ToolOverride
+
+	// narrowed records, for selectors made by narrowSelectors, which selector and
+	// selectors file entry they combine. It is nil for selectors from Config.Selectors.
+	narrowed *narrowedSelector
+}
+
+// narrowedSelector locates the two selectors combined by narrowSelectors.
+type narrowedSelector struct {
+	selector     int           // index in the bound selectors
+	fileSelector int           // index in the selectors file
+	timeout      time.Duration // timeout set by the selectors file entry, if any
+}
+
+// ToolOverride replaces parts of the tool generated from a command.
+// Empty fields keep the generated values.
+type ToolOverride struct {
+	// Name replaces the tool name, including the prefix. It may only contain
+	// letters, digits, '_', '-', and '.', and must be at most 128 characters.
+	Name string
+
+	// Title sets the human-readable title of the tool.
+	Title string
+
+	// Description replaces the tool description.
+	// Config.MaxDescriptionLength still applies, by truncation.
+	Description string
 }
 
 // enhanceFlagsSchema adds detailed flag information to the flags property.
@@ -74,18 +161,9 @@ func (s Selector) enhanceFlagsSchema(schema *jsonschema.Schema, cmd *cobra.Comma
 		schema.Properties = make(map[string]*jsonschema.Schema)
 	}
 
-	// basic filters
-	filter := func(flag *pflag.Flag) bool {
-		return flag.Hidden || flag.Deprecated != ""
-	}
-
 	// Process local flags
 	cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
-		if filter(flag) {
-			return
-		}
-
-		if s.LocalFlagSelector != nil && !s.LocalFlagSelector(flag) {
+		if s.dropFlagReason(flag, false) != "" {
 			return
 		}
 
@@ -99,22 +177,42 @@ func (s Selector) enhanceFlagsSchema(schema *jsonschema.Schema, cmd *cobra.Comma
 			return
 		}
 
-		if filter(flag) {
-			return
-		}
-
-		if s.InheritedFlagSelector != nil && !s.InheritedFlagSelector(flag) {
+		if s.dropFlagReason(flag, true) != "" {
 			return
 		}
 
 		flags.AddFlagToSchema(schema, flag)
 	})
 
+	// Constrain flags that cobra validates as groups
+	flags.AddFlagGroupsToSchema(schema, cmd.Flags())
+
 	// Set AdditionalProperties to false
 	// See https://github.com/google/jsonschema-go/issues/13
 	schema.AdditionalProperties = &jsonschema.Schema{Not: &jsonschema.Schema{}}
 }
 
+// dropFlagReason returns why flag is left out of tools created by s, or "" if it is included.
+// Hidden and deprecated flags are always left out.
+func (s Selector) dropFlagReason(flag *pflag.Flag, inherited bool) string {
+	switch {
+	case flag.Deprecated != "":
+		return "deprecated"
+	case flag.Hidden:
+		return "hidden"
+	}
+
+	if inherited {
+		if s.InheritedFlagSelector != nil && !s.InheritedFlagSelector(flag) {
+			return "rejected by InheritedFlagSelector " + Describe(s.InheritedFlagSelector)
+		}
+	} else if s.LocalFlagSelector != nil && !s.LocalFlagSelector(flag) {
+		return "rejected by LocalFlagSelector " + Describe(s.LocalFlagSelector)
+	}
+
+	return ""
+}
+
 // createToolFromCmd creates an MCP tool from a Cobra command.
 // The toolNamePrefix is used to replace the root command name in the tool name.
 func (s Selector) createToolFromCmd(cmd *cobra.Command, toolNamePrefix string) *mcp.Tool {
@@ -122,16 +220,72 @@ func (s Selector) createToolFromCmd(cmd *cobra.Command, toolNamePrefix string) *
 	s.enhanceFlagsSchema(schema.Properties["flags"], cmd)
 	enhanceArgsSchema(schema.Properties["args"], cmd)
 
+	// Commands that need positional arguments cannot omit args
+	if minItems := schema.Properties["args"].MinItems; minItems != nil && *minItems > 0 {
+		schema.Required = append(schema.Required, "args")
+	}
+
 	// Create the tool
+	override := s.toolOverride(cmd)
 	return &mcp.Tool{
-		Name:         toolName(cmd, toolNamePrefix),
-		Description:  toolDescription(cmd),
+		Name:         s.toolName(cmd, toolNamePrefix),
+		Title:        override.Title,
+		Description:  cmp.Or(override.Description, s.toolDescription(cmd)),
 		InputSchema:  schema,
-		OutputSchema: outputSchema.Copy(),
-		Annotations:  toolAnnotations(cmd),
+		OutputSchema: toolOutputSchema(cmd),
+		Annotations:  s.toolAnnotations(cmd),
 	}
 }
 
+// toolOverride returns the overrides for the tool created from cmd: s.Overrides,
+// with the AnnotationName and AnnotationDescription annotations filling in empty fields.
+func (s Selector) toolOverride(cmd *cobra.Command) ToolOverride {
+	override := s.Overrides[cmd.CommandPath()]
+	override.Name = cmp.Or(override.Name, cmd.Annotations[AnnotationName])
+	override.Description = cmp.Or(override.Description, cmd.Annotations[AnnotationDescription])
+	return override
+}
+
+// toolName returns the name of the tool created from cmd, applying any override.
+func (s Selector) toolName(cmd *cobra.Command, toolNamePrefix string) string {
+	return cmp.Or(s.toolOverride(cmd).Name, toolName(cmd, toolNamePrefix))
+}
+
+// toolOutputSchema returns the output schema for cmd,
+// using the command's AnnotationOutputSchema for the result field if it has one.
+func toolOutputSchema(cmd *cobra.Command) *jsonschema.Schema {
+	schema := outputSchema.Copy()
+	if result := resultSchema(cmd); result != nil {
+		if result.Description == "" {
+			result.Description = schema.Properties["result"].Description
+		}
+		schema.Properties["result"] = result
+	}
+
+	return schema
+}
+
+// toolAnnotations returns the MCP tool annotations of cmd, with s.Annotations
+// overriding the command's own annotations.
+func (s Selector) toolAnnotations(cmd *cobra.Command) *mcp.ToolAnnotations {
+	if len(s.Annotations) == 0 {
+		return toolAnnotations(cmd)
+	}
+
+	annotations := maps.Clone(cmd.Annotations)
+	if annotations == nil {
+		annotations = make(map[string]string, len(s.Annotations))
+	}
+
+	maps.Copy(annotations, s.Annotations)
+	return parseToolAnnotations(annotations)
+}
+
+// jsonOutput reports whether the stdout of cmd should be parsed as JSON.
+func (s Selector) jsonOutput(cmd *cobra.Command) bool {
+	return s.JSONOutput || cmd.Annotations[AnnotationOutput] == OutputFormatJSON
+}
+
 // enhanceArgsSchema adds detailed argument information to the args property.
 func enhanceArgsSchema(schema *jsonschema.Schema, cmd *cobra.Command) {
 	description := "Positional command line arguments"
@@ -150,6 +304,22 @@ func enhanceArgsSchema(schema *jsonschema.Schema, cmd *cobra.Command) {
 	}
 
 	schema.Description = description
+
+	// Constrain the argument count
+	if minArgs, maxArgs, ok := argsRange(cmd); ok {
+		if minArgs > 0 {
+			schema.MinItems = &minArgs
+		}
+		schema.MaxItems = maxArgs
+	}
+
+	// Constrain argument values, unless ValidArgs are only completion hints
+	if values := validArgs(cmd); len(values) > 0 && schema.Items != nil && onlyValidArgs(cmd) {
+		schema.Items.Enum = make([]any, 0, len(values))
+		for _, v := range values {
+			schema.Items.Enum = append(schema.Items.Enum, v)
+		}
+	}
 }
 
 // toolName creates a tool name from the command path.
@@ -172,6 +342,22 @@ func toolName(cmd *cobra.Command, toolNamePrefix string) string {
 	return strings.ReplaceAll(path, " ", "_")
 }
 
+// validateToolName reports an error if name is not a valid MCP tool name:
+// 1 to 128 letters, digits, '_', '-', or '.'.
+func validateToolName(name string) error {
+	if name == "" || len(name) > maxToolNameLength {
+		return fmt.Errorf("tool name %q must be 1 to %d characters long", name, maxToolNameLength)
+	}
+
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' && r != '-' && r != '.' {
+			return fmt.Errorf("tool name %q contains invalid character %q", name, r)
+		}
+	}
+
+	return nil
+}
+
 // toolDescription creates a comprehensive tool description.
 func toolDescription(cmd *cobra.Command) string {
 	var parts []string
@@ -193,16 +379,19 @@ func toolDescription(cmd *cobra.Command) string {
 	return strings.Join(parts, "\n")
 }
 
-func (s *Selector) execute(ctx context.Context, request *mcp.CallToolRequest, input ToolInput) (_ *mcp.CallToolResult, _ ToolOutput, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("panic: %v", r)
+// handler wraps next with the selector's middleware and recovers from panics.
+func (s Selector) handler(next ExecuteFunc) mcp.ToolHandlerFor[ToolInput, ToolOutput] {
+	return func(ctx context.Context, request *mcp.CallToolRequest, input ToolInput) (_ *mcp.CallToolResult, _ ToolOutput, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		if s.Middleware != nil {
+			return s.Middleware(ctx, request, input, next)
 		}
-	}()
 
-	if s.Middleware != nil {
-		return s.Middleware(ctx, request, input, execute)
+		return next(ctx, request, input)
 	}
-
-	return execute(ctx, request, input)
 }
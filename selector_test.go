@@ -2,6 +2,7 @@ package ophis
 
 import (
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/google/jsonschema-go/jsonschema"
@@ -331,6 +332,73 @@ func TestGenerateToolName(t *testing.T) {
 	})
 }
 
+func TestToolOverrides(t *testing.T) {
+	root := &cobra.Command{Use: "omnistrate-ctl"}
+	cell := &cobra.Command{Use: "deployment-cell"}
+	describe := &cobra.Command{
+		Use:   "describe-instance-details",
+		Short: "Describe the instance details of a deployment cell",
+		Run:   func(_ *cobra.Command, _ []string) {},
+	}
+	root.AddCommand(cell)
+	cell.AddCommand(describe)
+
+	t.Run("no overrides", func(t *testing.T) {
+		tool := Selector{}.createToolFromCmd(describe, "omnistrate-ctl")
+		assert.Equal(t, "omnistrate-ctl_deployment-cell_describe-instance-details", tool.Name)
+		assert.Empty(t, tool.Title)
+		assert.Equal(t, describe.Short, tool.Description)
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		describe.Annotations = map[string]string{
+			AnnotationName:        "omctl_cell_describe",
+			AnnotationDescription: "Show a cell",
+		}
+		t.Cleanup(func() { describe.Annotations = nil })
+
+		tool := Selector{}.createToolFromCmd(describe, "omnistrate-ctl")
+		assert.Equal(t, "omctl_cell_describe", tool.Name)
+		assert.Equal(t, "Show a cell", tool.Description)
+	})
+
+	t.Run("selector overrides win over annotations", func(t *testing.T) {
+		describe.Annotations = map[string]string{
+			AnnotationName:        "omctl_cell_describe",
+			AnnotationDescription: "Show a cell",
+		}
+		t.Cleanup(func() { describe.Annotations = nil })
+
+		s := Selector{
+			Overrides: map[string]ToolOverride{
+				"omnistrate-ctl deployment-cell describe-instance-details": {
+					Name:  "describe_cell",
+					Title: "Describe cell",
+				},
+			},
+		}
+		tool := s.createToolFromCmd(describe, "omnistrate-ctl")
+		assert.Equal(t, "describe_cell", tool.Name)
+		assert.Equal(t, "Describe cell", tool.Title)
+		assert.Equal(t, "Show a cell", tool.Description, "annotation fills in fields the override leaves empty")
+	})
+
+	t.Run("overrides for other commands", func(t *testing.T) {
+		s := Selector{
+			Overrides: map[string]ToolOverride{"omnistrate-ctl deployment-cell": {Name: "cell"}},
+		}
+		tool := s.createToolFromCmd(describe, "omctl")
+		assert.Equal(t, "omctl_deployment-cell_describe-instance-details", tool.Name)
+	})
+}
+
+func TestValidateToolName(t *testing.T) {
+	assert.NoError(t, validateToolName("omctl_cell.describe-1"))
+	assert.ErrorContains(t, validateToolName(""), "must be 1 to 128 characters long")
+	assert.ErrorContains(t, validateToolName(strings.Repeat("a", 129)), "must be 1 to 128 characters long")
+	assert.ErrorContains(t, validateToolName("cell describe"), `invalid character ' '`)
+}
+
 func TestGenerateToolDescription(t *testing.T) {
 	t.Run("Long and Example", func(t *testing.T) {
 		cmd1 := &cobra.Command{
@@ -353,3 +421,94 @@ func TestGenerateToolDescription(t *testing.T) {
 		assert.Equal(t, "Short description of cmd2", desc2)
 	})
 }
+
+func TestToolOutputSchema(t *testing.T) {
+	t.Run("default result schema", func(t *testing.T) {
+		schema := toolOutputSchema(&cobra.Command{Use: "test"})
+		require.Contains(t, schema.Properties, "result")
+		assert.Empty(t, schema.Properties["result"].Type)
+	})
+
+	t.Run("annotated result schema", func(t *testing.T) {
+		cmd := &cobra.Command{
+			Use: "test",
+			Annotations: map[string]string{
+				AnnotationOutput:       OutputFormatJSON,
+				AnnotationOutputSchema: `{"type":"array","items":{"type":"string"}}`,
+			},
+		}
+
+		schema := toolOutputSchema(cmd)
+		result := schema.Properties["result"]
+		assert.Equal(t, "array", result.Type)
+		assert.Equal(t, "string", result.Items.Type)
+		assert.NotEmpty(t, result.Description)
+		assert.True(t, Selector{}.jsonOutput(cmd))
+	})
+
+	t.Run("invalid result schema is ignored", func(t *testing.T) {
+		cmd := &cobra.Command{
+			Use:         "test",
+			Annotations: map[string]string{AnnotationOutputSchema: `{"type":`},
+		}
+
+		schema := toolOutputSchema(cmd)
+		assert.Empty(t, schema.Properties["result"].Type)
+		assert.False(t, Selector{}.jsonOutput(cmd))
+		assert.True(t, Selector{JSONOutput: true}.jsonOutput(cmd))
+	})
+}
+
+// quantityValue is a custom flag type for TestRegisterFlagType.
+type quantityValue string
+
+func (v *quantityValue) String() string     { return string(*v) }
+func (v *quantityValue) Set(s string) error { *v = quantityValue(s); return nil }
+func (v *quantityValue) Type() string       { return "quantity" }
+
+func TestRegisterFlagType(t *testing.T) {
+	RegisterFlagType("quantity", func(*pflag.Flag) *jsonschema.Schema {
+		return &jsonschema.Schema{Type: "string", Pattern: `^[0-9]+(Mi|Gi)$`}
+	})
+	t.Cleanup(func() { RegisterFlagType("quantity", nil) })
+
+	memory := quantityValue("")
+	cmd := &cobra.Command{Use: "run", Run: func(_ *cobra.Command, _ []string) {}}
+	cmd.Flags().Var(&memory, "memory", "Memory limit")
+
+	tool := Selector{}.createToolFromCmd(cmd, "root")
+	flagSchema := tool.InputSchema.(*jsonschema.Schema).Properties["flags"].Properties["memory"]
+	require.NotNil(t, flagSchema)
+	assert.Equal(t, "string", flagSchema.Type)
+	assert.Equal(t, `^[0-9]+(Mi|Gi)$`, flagSchema.Pattern)
+	assert.Equal(t, "Memory limit", flagSchema.Description)
+}
+
+func TestSetFlagSchema(t *testing.T) {
+	root := &cobra.Command{Use: "root"}
+	root.PersistentFlags().String("region", "", "Cloud region")
+	cmd := &cobra.Command{Use: "scale", Run: func(_ *cobra.Command, _ []string) {}}
+	cmd.Flags().Int("replicas", 1, "Number of replicas")
+	root.AddCommand(cmd)
+
+	require.NoError(t, SetFlagSchema(cmd, "replicas", &jsonschema.Schema{
+		Minimum: jsonschema.Ptr(1.0),
+		Maximum: jsonschema.Ptr(10.0),
+	}))
+	require.NoError(t, SetFlagSchema(root, "region", &jsonschema.Schema{Enum: []any{"eu", "us"}}))
+	assert.Error(t, SetFlagSchema(cmd, "missing", &jsonschema.Schema{}))
+
+	tool := Selector{}.createToolFromCmd(cmd, "root")
+	flags := tool.InputSchema.(*jsonschema.Schema).Properties["flags"]
+
+	replicas := flags.Properties["replicas"]
+	assert.Equal(t, "integer", replicas.Type)
+	assert.Equal(t, 1.0, *replicas.Minimum)
+	assert.Equal(t, 10.0, *replicas.Maximum)
+	assert.Equal(t, "Number of replicas", replicas.Description)
+	assert.JSONEq(t, `1`, string(replicas.Default))
+
+	region := flags.Properties["region"]
+	assert.Equal(t, "string", region.Type)
+	assert.Equal(t, []any{"eu", "us"}, region.Enum)
+}
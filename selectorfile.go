@@ -0,0 +1,234 @@
+package ophis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// selectorFile is the contents of a selector file.
+type selectorFile struct {
+	Selectors []fileSelector `json:"selectors" yaml:"selectors"`
+}
+
+// fileSelector is a selector in a selector file.
+type fileSelector struct {
+	// Commands are globs, in the syntax of AllowCmdsMatching, picking the commands it applies to.
+	Commands patternList `json:"commands" yaml:"commands"`
+	// Flags are globs, in the syntax of AllowFlagsMatching, picking the local flags to expose.
+	Flags flagList `json:"flags" yaml:"flags"`
+	// InheritedFlags are globs picking the inherited flags to expose.
+	InheritedFlags flagList `json:"inheritedFlags" yaml:"inheritedFlags"`
+	// Timeout is a time.ParseDuration duration.
+	Timeout string `json:"timeout" yaml:"timeout"`
+	// Annotations are MCP tool annotations, with string or boolean values.
+	Annotations map[string]any `json:"annotations" yaml:"annotations"`
+}
+
+// patternList lists globs to include and exclude.
+type patternList struct {
+	Include []string `json:"include" yaml:"include"`
+	Exclude []string `json:"exclude" yaml:"exclude"`
+}
+
+// flagList lists flag globs to allow and deny.
+type flagList struct {
+	Allow []string `json:"allow" yaml:"allow"`
+	Deny  []string `json:"deny" yaml:"deny"`
+}
+
+// LoadConfigFile reads selectors from a YAML or JSON file. Files ending in ".json" are
+// read as JSON, and any other file as YAML. See docs/config.md for the format.
+//
+// The selectors are meant to narrow the selectors defined in code, as Config.SelectorsFile
+// does: on their own, they would expose everything they match.
+func LoadConfigFile(path string) ([]Selector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selector file: %w", err)
+	}
+
+	var file selectorFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse selector file %s: %w", path, err)
+	}
+
+	if len(file.Selectors) == 0 {
+		return nil, fmt.Errorf("selector file %s defines no selectors", path)
+	}
+
+	selectors := make([]Selector, 0, len(file.Selectors))
+	for i, fs := range file.Selectors {
+		selector, err := fs.compile()
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %d in %s: %w", i, path, err)
+		}
+
+		selectors = append(selectors, selector)
+	}
+
+	return selectors, nil
+}
+
+// compile turns fs into a Selector.
+func (fs fileSelector) compile() (Selector, error) {
+	var s Selector
+	for _, glob := range slices.Concat(fs.Commands.Include, fs.Commands.Exclude) {
+		for _, segment := range strings.Fields(glob) {
+			if _, err := path.Match(segment, ""); err != nil {
+				return s, fmt.Errorf("invalid command glob %q: %w", glob, err)
+			}
+		}
+	}
+
+	for _, glob := range slices.Concat(fs.Flags.Allow, fs.Flags.Deny, fs.InheritedFlags.Allow, fs.InheritedFlags.Deny) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return s, fmt.Errorf("invalid flag glob %q: %w", glob, err)
+		}
+	}
+
+	s.CmdSelector = patternSelector(fs.Commands.Include, fs.Commands.Exclude, AllowCmdsMatching, ExcludeCmdsMatching)
+	s.LocalFlagSelector = patternSelector(fs.Flags.Allow, fs.Flags.Deny, AllowFlagsMatching, ExcludeFlagsMatching)
+	s.InheritedFlagSelector = patternSelector(fs.InheritedFlags.Allow, fs.InheritedFlags.Deny, AllowFlagsMatching, ExcludeFlagsMatching)
+
+	if fs.Timeout != "" {
+		timeout, err := time.ParseDuration(fs.Timeout)
+		if err != nil {
+			return s, fmt.Errorf("invalid timeout: %w", err)
+		}
+
+		s.Timeout = timeout
+	}
+
+	if len(fs.Annotations) > 0 {
+		s.Annotations = make(map[string]string, len(fs.Annotations))
+		for key, value := range fs.Annotations {
+			v, err := fileAnnotation(key, value)
+			if err != nil {
+				return s, err
+			}
+
+			s.Annotations[key] = v
+		}
+	}
+
+	return s, nil
+}
+
+// patternSelector combines the allow and exclude selectors for include and exclude,
+// returning nil when neither is set.
+func patternSelector[S ~func(T) bool, T any](include, exclude []string, allow, deny func(...string) S) S {
+	var selectors []S
+	if len(include) > 0 {
+		selectors = append(selectors, allow(include...))
+	}
+
+	if len(exclude) > 0 {
+		selectors = append(selectors, deny(exclude...))
+	}
+
+	switch len(selectors) {
+	case 0:
+		return nil
+	case 1:
+		return selectors[0]
+	default:
+		return And(selectors...)
+	}
+}
+
+// fileAnnotation checks an annotation from a selector file and returns its string value.
+func fileAnnotation(key string, value any) (string, error) {
+	switch key {
+	case AnnotationTitle:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+
+		return "", fmt.Errorf("annotation %s must be a string", key)
+	case AnnotationReadOnly, AnnotationDestructive, AnnotationIdempotent, AnnotationOpenWorld:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			if _, err := strconv.ParseBool(v); err == nil {
+				return v, nil
+			}
+		}
+
+		return "", fmt.Errorf("annotation %s must be a boolean", key)
+	default:
+		return "", fmt.Errorf("unknown annotation %q", key)
+	}
+}
+
+// narrowSelectors returns selectors that apply the first matching selector of bound and
+// the first matching selector of narrow together: a command is exposed only if both
+// match it, and a flag only if both of their flag selectors accept it.
+// The rest of the settings come from the bound selector, except that narrow can
+// override annotations. The timeout of narrow is kept apart, so that Config.toolTimeout
+// can apply it on top of every other timeout.
+func narrowSelectors(bound, narrow []Selector) []Selector {
+	if len(bound) == 0 {
+		bound = []Selector{{}}
+	}
+
+	// Whether a narrow selector matches does not depend on the bound selector, so trying
+	// every pair in order finds the first matching bound selector with the first
+	// matching narrow selector.
+	selectors := make([]Selector, 0, len(bound)*len(narrow))
+	for i, b := range bound {
+		for j, n := range narrow {
+			s := b
+			s.narrowed = &narrowedSelector{selector: i, fileSelector: j, timeout: n.Timeout}
+			s.CmdSelector = And(b.CmdSelector, n.CmdSelector)
+			s.LocalFlagSelector = And(b.LocalFlagSelector, n.LocalFlagSelector)
+			s.InheritedFlagSelector = And(b.InheritedFlagSelector, n.InheritedFlagSelector)
+
+			if len(n.Annotations) > 0 {
+				s.Annotations = make(map[string]string, len(b.Annotations)+len(n.Annotations))
+				maps.Copy(s.Annotations, b.Annotations)
+				maps.Copy(s.Annotations, n.Annotations)
+			}
+
+			selectors = append(selectors, s)
+		}
+	}
+
+	return selectors
+}
+
+// applySelectorsFile narrows c.Selectors with the selectors in c.SelectorsFile, if set.
+func (c *Config) applySelectorsFile() error {
+	if c.SelectorsFile == "" {
+		return nil
+	}
+
+	selectors, err := LoadConfigFile(c.SelectorsFile)
+	if err != nil {
+		return err
+	}
+
+	c.Selectors = narrowSelectors(c.Selectors, selectors)
+	return nil
+}
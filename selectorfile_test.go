@@ -0,0 +1,277 @@
+package ophis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelectorFile writes content to a file called name in a temporary directory.
+func writeSelectorFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	yamlFile := `
+selectors:
+  - commands:
+      include: ["kubectl get **"]
+      exclude: ["* * secrets"]
+    flags:
+      allow: ["namespace", "output*"]
+    inheritedFlags:
+      deny: ["token"]
+    timeout: 30s
+    annotations:
+      readOnlyHint: true
+      title: Read resources
+  - commands:
+      include: ["kubectl logs"]
+`
+	jsonFile := `{
+  "selectors": [
+    {
+      "commands": {"include": ["kubectl get **"], "exclude": ["* * secrets"]},
+      "flags": {"allow": ["namespace", "output*"]},
+      "inheritedFlags": {"deny": ["token"]},
+      "timeout": "30s",
+      "annotations": {"readOnlyHint": true, "title": "Read resources"}
+    },
+    {"commands": {"include": ["kubectl logs"]}}
+  ]
+}`
+
+	for name, content := range map[string]string{"selectors.yaml": yamlFile, "selectors.json": jsonFile} {
+		t.Run(name, func(t *testing.T) {
+			selectors, err := LoadConfigFile(writeSelectorFile(t, name, content))
+			require.NoError(t, err)
+			require.Len(t, selectors, 2)
+
+			s := selectors[0]
+			assert.True(t, s.CmdSelector(buildCommandTree("kubectl", "get", "pods")))
+			assert.False(t, s.CmdSelector(buildCommandTree("kubectl", "get", "secrets")))
+			assert.False(t, s.CmdSelector(buildCommandTree("kubectl", "delete")))
+			assert.True(t, s.LocalFlagSelector(&pflag.Flag{Name: "output-format"}))
+			assert.False(t, s.LocalFlagSelector(&pflag.Flag{Name: "selector"}))
+			assert.False(t, s.InheritedFlagSelector(&pflag.Flag{Name: "token"}))
+			assert.True(t, s.InheritedFlagSelector(&pflag.Flag{Name: "context"}))
+			assert.Equal(t, 30*time.Second, s.Timeout)
+			assert.Equal(t, map[string]string{AnnotationReadOnly: "true", AnnotationTitle: "Read resources"}, s.Annotations)
+
+			s = selectors[1]
+			assert.True(t, s.CmdSelector(buildCommandTree("kubectl", "logs")))
+			assert.Nil(t, s.LocalFlagSelector)
+			assert.Nil(t, s.InheritedFlagSelector)
+			assert.Zero(t, s.Timeout)
+		})
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "unknown yaml field",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - command:\n      include: [\"a\"]\n",
+			expected: "field command not found",
+		},
+		{
+			name:     "unknown json field",
+			file:     "selectors.json",
+			content:  `{"selectors": [{"command": {}}]}`,
+			expected: `unknown field "command"`,
+		},
+		{
+			name:     "no selectors",
+			file:     "selectors.yaml",
+			content:  "selectors: []\n",
+			expected: "defines no selectors",
+		},
+		{
+			name:     "malformed command glob",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - commands:\n      include: [\"kubectl [\"]\n",
+			expected: `invalid selector 0`,
+		},
+		{
+			name:     "malformed flag glob",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - flags:\n      deny: [\"[\"]\n",
+			expected: `invalid flag glob "["`,
+		},
+		{
+			name:     "invalid timeout",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - timeout: soon\n",
+			expected: "invalid timeout",
+		},
+		{
+			name:     "unknown annotation",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - annotations:\n      readonly: true\n",
+			expected: `unknown annotation "readonly"`,
+		},
+		{
+			name:     "invalid hint",
+			file:     "selectors.yaml",
+			content:  "selectors:\n  - annotations:\n      destructiveHint: sometimes\n",
+			expected: "annotation destructiveHint must be a boolean",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigFile(writeSelectorFile(t, tt.file, tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read selector file")
+	})
+}
+
+func TestNarrowSelectors(t *testing.T) {
+	bound := []Selector{
+		{
+			CmdSelector:       AllowCmdsMatching("kubectl get **"),
+			LocalFlagSelector: ExcludeFlags("token"),
+			Timeout:           time.Minute,
+			MaxOutputBytes:    100,
+		},
+		{
+			CmdSelector: AllowCmds("kubectl logs"),
+		},
+	}
+	narrow := []Selector{
+		{
+			CmdSelector: AllowCmdsMatching("* * pods"),
+			Timeout:     10 * time.Second,
+			Annotations: map[string]string{AnnotationReadOnly: "true"},
+		},
+		{
+			CmdSelector:       AllowCmdsMatching("kubectl **"),
+			LocalFlagSelector: AllowFlags("namespace", "token"),
+			Timeout:           time.Hour,
+		},
+	}
+
+	selectors := narrowSelectors(bound, narrow)
+	require.Len(t, selectors, 4)
+
+	// first matching selector, as registerToolsRecursive picks it
+	match := func(names ...string) *Selector {
+		cmd := buildCommandTree(names...)
+		for i := range selectors {
+			if selectors[i].CmdSelector(cmd) {
+				return &selectors[i]
+			}
+		}
+
+		return nil
+	}
+
+	t.Run("both match", func(t *testing.T) {
+		s := match("kubectl", "get", "pods")
+		require.NotNil(t, s)
+		assert.Equal(t, 10*time.Second, (&Config{}).toolTimeout(*s, buildCommandTree("kubectl", "get", "pods")))
+		assert.Equal(t, 100, s.MaxOutputBytes)
+		assert.Equal(t, map[string]string{AnnotationReadOnly: "true"}, s.Annotations)
+		assert.False(t, s.LocalFlagSelector(&pflag.Flag{Name: "token"}))
+	})
+
+	t.Run("narrow cannot widen flags or timeout", func(t *testing.T) {
+		s := match("kubectl", "get", "services")
+		require.NotNil(t, s)
+		assert.Equal(t, time.Minute, (&Config{}).toolTimeout(*s, buildCommandTree("kubectl", "get", "services")))
+		assert.True(t, s.LocalFlagSelector(&pflag.Flag{Name: "namespace"}))
+		assert.False(t, s.LocalFlagSelector(&pflag.Flag{Name: "token"}))
+		assert.False(t, s.LocalFlagSelector(&pflag.Flag{Name: "output"}))
+	})
+
+	t.Run("narrow cannot widen commands", func(t *testing.T) {
+		assert.Nil(t, match("kubectl", "delete", "pods"))
+	})
+
+	t.Run("second bound selector", func(t *testing.T) {
+		s := match("kubectl", "logs")
+		require.NotNil(t, s)
+		assert.Equal(t, time.Hour, (&Config{}).toolTimeout(*s, buildCommandTree("kubectl", "logs")))
+	})
+
+	t.Run("narrow cannot exceed the default timeout", func(t *testing.T) {
+		selectors := narrowSelectors(bound[1:], narrow[1:])
+		require.Len(t, selectors, 1)
+		c := &Config{Timeout: 2 * time.Minute}
+		assert.Equal(t, 2*time.Minute, c.toolTimeout(selectors[0], buildCommandTree("kubectl", "logs")))
+	})
+
+	t.Run("narrow shortens the timeout annotation", func(t *testing.T) {
+		cmd := buildCommandTree("kubectl", "get", "pods")
+		cmd.Annotations = map[string]string{AnnotationTimeout: "20m"}
+		assert.Equal(t, 10*time.Second, (&Config{}).toolTimeout(selectors[0], cmd))
+
+		cmd.Annotations[AnnotationTimeout] = "5s"
+		assert.Equal(t, 5*time.Second, (&Config{}).toolTimeout(selectors[0], cmd))
+	})
+
+	t.Run("empty bound exposes what narrow matches", func(t *testing.T) {
+		selectors := narrowSelectors(nil, narrow[:1])
+		require.Len(t, selectors, 1)
+		assert.True(t, selectors[0].CmdSelector(buildCommandTree("cli", "list", "pods")))
+		assert.False(t, selectors[0].CmdSelector(buildCommandTree("cli", "list")))
+	})
+}
+
+func TestSelectorsFile(t *testing.T) {
+	root := &cobra.Command{Use: "kubectl"}
+	get := &cobra.Command{Use: "get", Run: func(_ *cobra.Command, _ []string) {}}
+	get.Flags().String("namespace", "", "namespace")
+	get.Flags().String("token", "", "token")
+	del := &cobra.Command{Use: "delete", Run: func(_ *cobra.Command, _ []string) {}}
+	root.AddCommand(get, del)
+
+	c := &Config{
+		Selectors: []Selector{{
+			LocalFlagSelector: ExcludeFlags("token"),
+		}},
+		SelectorsFile: writeSelectorFile(t, "selectors.yaml", `
+selectors:
+  - commands:
+      exclude: ["* delete"]
+    annotations:
+      readOnlyHint: true
+`),
+	}
+
+	require.NoError(t, c.applySelectorsFile())
+	c.registerTools(root)
+
+	require.Len(t, c.tools, 1)
+	tool := c.tools[0]
+	assert.Equal(t, "kubectl_get", tool.Name)
+	assert.Equal(t, &mcp.ToolAnnotations{ReadOnlyHint: true}, tool.Annotations)
+
+	flags := tool.InputSchema.(*jsonschema.Schema).Properties["flags"].Properties
+	assert.Contains(t, flags, "namespace")
+	assert.NotContains(t, flags, "token")
+}
@@ -1,7 +1,11 @@
 package ophis
 
 import (
+	"fmt"
+	"path"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -11,7 +15,7 @@ import (
 // AllowCmdsContaining creates a selector that only accepts commands whose path contains a listed phrase.
 // Example: AllowCmdsContaining("get", "helm list") includes "kubectl get pods" and "helm list".
 func AllowCmdsContaining(substrings ...string) CmdSelector {
-	return func(cmd *cobra.Command) bool {
+	return described(func(cmd *cobra.Command) bool {
 		for _, s := range substrings {
 			if strings.Contains(cmd.CommandPath(), s) {
 				return true
@@ -19,51 +23,263 @@ func AllowCmdsContaining(substrings ...string) CmdSelector {
 		}
 
 		return false
-	}
+	}, "AllowCmdsContaining(%s)", quoteAll(substrings))
 }
 
 // ExcludeCmdsContaining creates a selector that rejects commands whose path contains any listed phrase.
 // Example: ExcludeCmdsContaining("kubectl delete", "admin") excludes "kubectl delete" and "cli admin user".
 func ExcludeCmdsContaining(substrings ...string) CmdSelector {
 	selector := AllowCmdsContaining(substrings...)
-	return func(cmd *cobra.Command) bool {
+	return described(func(cmd *cobra.Command) bool {
 		return !selector(cmd)
-	}
+	}, "ExcludeCmdsContaining(%s)", quoteAll(substrings))
 }
 
 // AllowCmds creates a selector that only accepts commands whose path is listed.
 // Example: AllowCmds("kubectl get", "helm list") includes only those exact commands.
 func AllowCmds(cmds ...string) CmdSelector {
-	return func(cmd *cobra.Command) bool {
+	return described(func(cmd *cobra.Command) bool {
 		return slices.Contains(cmds, cmd.CommandPath())
-	}
+	}, "AllowCmds(%s)", quoteAll(cmds))
 }
 
 // ExcludeCmds creates a selector that rejects commands whose path is listed.
 // Example: ExcludeCmds("kubectl delete", "helm uninstall") excludes those exact commands.
 func ExcludeCmds(cmds ...string) CmdSelector {
-	return func(cmd *cobra.Command) bool {
+	return described(func(cmd *cobra.Command) bool {
 		return !slices.Contains(cmds, cmd.CommandPath())
+	}, "ExcludeCmds(%s)", quoteAll(cmds))
+}
+
+// AllowCmdsMatching creates a selector that only accepts commands whose path matches a listed glob.
+// Globs are matched segment by segment against the space-separated command path:
+// "*" matches any part of a single segment (see path.Match for the full syntax),
+// and a "**" segment matches zero or more segments.
+// Example: AllowCmdsMatching("kubectl get *") includes "kubectl get pods" but not
+// "kubectl get" or "kubectl target"; AllowCmdsMatching("kubectl config **") includes
+// "kubectl config" and every command below it.
+// It panics if a glob is malformed.
+func AllowCmdsMatching(globs ...string) CmdSelector {
+	patterns := make([][]string, 0, len(globs))
+	for _, glob := range globs {
+		pattern := strings.Fields(glob)
+		for _, segment := range pattern {
+			if _, err := path.Match(segment, ""); err != nil {
+				panic(fmt.Sprintf("ophis: invalid command glob %q: %v", glob, err))
+			}
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return described(func(cmd *cobra.Command) bool {
+		segments := strings.Fields(cmd.CommandPath())
+		return slices.ContainsFunc(patterns, func(pattern []string) bool {
+			return matchSegments(pattern, segments)
+		})
+	}, "AllowCmdsMatching(%s)", quoteAll(globs))
+}
+
+// ExcludeCmdsMatching creates a selector that rejects commands whose path matches any listed glob.
+// Globs use the syntax of AllowCmdsMatching.
+// Example: ExcludeCmdsMatching("kubectl delete **", "* * secrets") excludes every delete
+// command and every "secrets" command two levels below the root.
+func ExcludeCmdsMatching(globs ...string) CmdSelector {
+	selector := AllowCmdsMatching(globs...)
+	return described(func(cmd *cobra.Command) bool {
+		return !selector(cmd)
+	}, "ExcludeCmdsMatching(%s)", quoteAll(globs))
+}
+
+// AllowCmdsMatchingRegexp creates a selector that only accepts commands whose whole path
+// matches a listed regular expression.
+// Example: AllowCmdsMatchingRegexp(`kubectl (get|describe) \w+`) includes "kubectl get pods".
+// It panics if an expression does not compile.
+func AllowCmdsMatchingRegexp(exprs ...string) CmdSelector {
+	regexps := compileAnchored(exprs)
+	return described(func(cmd *cobra.Command) bool {
+		return matchesAny(regexps, cmd.CommandPath())
+	}, "AllowCmdsMatchingRegexp(%s)", quoteAll(exprs))
+}
+
+// ExcludeCmdsMatchingRegexp creates a selector that rejects commands whose whole path
+// matches any listed regular expression.
+// Example: ExcludeCmdsMatchingRegexp(`\S+ (delete|drain)( .*)?`) excludes destructive subcommands.
+func ExcludeCmdsMatchingRegexp(exprs ...string) CmdSelector {
+	selector := AllowCmdsMatchingRegexp(exprs...)
+	return described(func(cmd *cobra.Command) bool {
+		return !selector(cmd)
+	}, "ExcludeCmdsMatchingRegexp(%s)", quoteAll(exprs))
+}
+
+// AllowCmdsWithAnnotation creates a selector that only accepts commands whose
+// annotation key is set to exactly value.
+// Example: AllowCmdsWithAnnotation("mcp", "true") includes commands with cmd.Annotations["mcp"] == "true".
+func AllowCmdsWithAnnotation(key, value string) CmdSelector {
+	return described(func(cmd *cobra.Command) bool {
+		v, ok := cmd.Annotations[key]
+		return ok && v == value
+	}, "AllowCmdsWithAnnotation(%q, %q)", key, value)
+}
+
+// AllowCmdsInGroup creates a selector that only accepts commands in a listed group.
+// A command is in a group if it, or the nearest ancestor with a GroupID, has that GroupID,
+// so the subcommands of a grouped command belong to its group.
+// Example: AllowCmdsInGroup("basic") includes "kubectl get" and "kubectl get pods"
+// when "kubectl get" has GroupID "basic".
+func AllowCmdsInGroup(groupIDs ...string) CmdSelector {
+	return described(func(cmd *cobra.Command) bool {
+		for c := cmd; c != nil; c = c.Parent() {
+			if c.GroupID != "" {
+				return slices.Contains(groupIDs, c.GroupID)
+			}
+		}
+
+		return false
+	}, "AllowCmdsInGroup(%s)", quoteAll(groupIDs))
+}
+
+// AllowReadOnlyCmds creates a selector that only accepts commands whose
+// AnnotationReadOnly annotation is true.
+func AllowReadOnlyCmds() CmdSelector {
+	return described(func(cmd *cobra.Command) bool {
+		return annotationTrue(cmd, AnnotationReadOnly)
+	}, "AllowReadOnlyCmds()")
+}
+
+// ExcludeDestructiveCmds creates a selector that rejects commands that may be destructive.
+// As in MCP, where destructiveHint defaults to true for tools that are not read-only,
+// a command is only accepted if its AnnotationReadOnly annotation is true or its
+// AnnotationDestructive annotation is false. Commands without these annotations are rejected.
+func ExcludeDestructiveCmds() CmdSelector {
+	return described(func(cmd *cobra.Command) bool {
+		return annotationTrue(cmd, AnnotationReadOnly) || annotationFalse(cmd, AnnotationDestructive)
+	}, "ExcludeDestructiveCmds()")
+}
+
+// AllowFlagsMatching creates a selector that only accepts flags whose name matches a listed glob.
+// Globs use path.Match syntax.
+// Example: AllowFlagsMatching("*-timeout", "output") includes "request-timeout" and "output".
+// It panics if a glob is malformed.
+func AllowFlagsMatching(globs ...string) FlagSelector {
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			panic(fmt.Sprintf("ophis: invalid flag glob %q: %v", glob, err))
+		}
 	}
+
+	return described(func(flag *pflag.Flag) bool {
+		return slices.ContainsFunc(globs, func(glob string) bool {
+			ok, _ := path.Match(glob, flag.Name)
+			return ok
+		})
+	}, "AllowFlagsMatching(%s)", quoteAll(globs))
+}
+
+// ExcludeFlagsMatching creates a selector that rejects flags whose name matches any listed glob.
+// Example: ExcludeFlagsMatching("*token*", "as-*") excludes "token", "id-token" and "as-group".
+func ExcludeFlagsMatching(globs ...string) FlagSelector {
+	selector := AllowFlagsMatching(globs...)
+	return described(func(flag *pflag.Flag) bool {
+		return !selector(flag)
+	}, "ExcludeFlagsMatching(%s)", quoteAll(globs))
+}
+
+// AllowFlagsMatchingRegexp creates a selector that only accepts flags whose whole name
+// matches a listed regular expression.
+// Example: AllowFlagsMatchingRegexp(`(name)?space`) includes "namespace" and "space".
+// It panics if an expression does not compile.
+func AllowFlagsMatchingRegexp(exprs ...string) FlagSelector {
+	regexps := compileAnchored(exprs)
+	return described(func(flag *pflag.Flag) bool {
+		return matchesAny(regexps, flag.Name)
+	}, "AllowFlagsMatchingRegexp(%s)", quoteAll(exprs))
+}
+
+// ExcludeFlagsMatchingRegexp creates a selector that rejects flags whose whole name
+// matches any listed regular expression.
+// Example: ExcludeFlagsMatchingRegexp(`.*(password|secret).*`) excludes credential flags.
+func ExcludeFlagsMatchingRegexp(exprs ...string) FlagSelector {
+	selector := AllowFlagsMatchingRegexp(exprs...)
+	return described(func(flag *pflag.Flag) bool {
+		return !selector(flag)
+	}, "ExcludeFlagsMatchingRegexp(%s)", quoteAll(exprs))
 }
 
 // AllowFlags creates a selector that only accepts flags whose name is listed.
 // Example: AllowFlags("namespace", "output") includes only flags named "namespace" and "output".
 func AllowFlags(names ...string) FlagSelector {
-	return func(flag *pflag.Flag) bool {
+	return described(func(flag *pflag.Flag) bool {
 		return slices.Contains(names, flag.Name)
-	}
+	}, "AllowFlags(%s)", quoteAll(names))
 }
 
 // ExcludeFlags creates a selector that rejects flags whose name is listed.
 // Example: ExcludeFlags("color", "kubeconfig") excludes flags named "color" and "kubeconfig".
 func ExcludeFlags(names ...string) FlagSelector {
-	return func(flag *pflag.Flag) bool {
+	return described(func(flag *pflag.Flag) bool {
 		return !slices.Contains(names, flag.Name)
-	}
+	}, "ExcludeFlags(%s)", quoteAll(names))
 }
 
 // NoFlags is a FlagSelector that excludes all flags.
 func NoFlags(_ *pflag.Flag) bool {
 	return false
 }
+
+// matchSegments reports whether segments match pattern, where a "**" pattern segment
+// matches zero or more segments and other pattern segments use path.Match.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchSegments(pattern[1:], segments[1:])
+}
+
+// compileAnchored compiles each expression to match whole strings only.
+// It panics if an expression does not compile.
+func compileAnchored(exprs []string) []*regexp.Regexp {
+	regexps := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		regexps = append(regexps, regexp.MustCompile(`^(?:`+expr+`)$`))
+	}
+
+	return regexps
+}
+
+// matchesAny reports whether s matches any of regexps.
+func matchesAny(regexps []*regexp.Regexp, s string) bool {
+	return slices.ContainsFunc(regexps, func(re *regexp.Regexp) bool {
+		return re.MatchString(s)
+	})
+}
+
+// annotationTrue reports whether the boolean annotation key of cmd is true.
+// Invalid values count as false; toolAnnotations warns about them.
+func annotationTrue(cmd *cobra.Command, key string) bool {
+	b, err := strconv.ParseBool(cmd.Annotations[key])
+	return err == nil && b
+}
+
+// annotationFalse reports whether the boolean annotation key of cmd is false.
+// Missing and invalid values do not count as false.
+func annotationFalse(cmd *cobra.Command, key string) bool {
+	b, err := strconv.ParseBool(cmd.Annotations[key])
+	return err == nil && !b
+}
@@ -3,6 +3,7 @@ package ophis
 import (
 	"testing"
 
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
@@ -467,3 +468,247 @@ func TestNoFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestAllowCmdsMatching(t *testing.T) {
+	tests := []struct {
+		name         string
+		globs        []string
+		commandNames []string
+		expected     bool
+	}{
+		{"single wildcard segment", []string{"kubectl get *"}, []string{"kubectl", "get", "pods"}, true},
+		{"wildcard needs a segment", []string{"kubectl get *"}, []string{"kubectl", "get"}, false},
+		{"wildcard matches one segment only", []string{"kubectl get *"}, []string{"kubectl", "get", "pods", "all"}, false},
+		{"no substring matches", []string{"* get"}, []string{"cli", "target"}, false},
+		{"partial segment wildcard", []string{"kubectl get pod*"}, []string{"kubectl", "get", "pods"}, true},
+		{"globstar matches zero segments", []string{"kubectl config **"}, []string{"kubectl", "config"}, true},
+		{"globstar matches many segments", []string{"kubectl config **"}, []string{"kubectl", "config", "view", "raw"}, true},
+		{"globstar in middle", []string{"kubectl ** list"}, []string{"kubectl", "a", "b", "list"}, true},
+		{"globstar in middle mismatch", []string{"kubectl ** list"}, []string{"kubectl", "a", "b", "get"}, false},
+		{"exact path", []string{"helm list"}, []string{"helm", "list"}, true},
+		{"one of multiple globs", []string{"helm *", "kubectl get *"}, []string{"kubectl", "get", "pods"}, true},
+		{"no globs", []string{}, []string{"kubectl"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := buildCommandTree(tt.commandNames...)
+			assert.Equal(t, tt.expected, AllowCmdsMatching(tt.globs...)(cmd))
+			assert.Equal(t, !tt.expected, ExcludeCmdsMatching(tt.globs...)(cmd))
+		})
+	}
+
+	assert.Panics(t, func() { AllowCmdsMatching("kubectl [") })
+}
+
+func TestAllowCmdsMatchingRegexp(t *testing.T) {
+	tests := []struct {
+		name         string
+		exprs        []string
+		commandNames []string
+		expected     bool
+	}{
+		{"alternation", []string{`kubectl (get|describe) \w+`}, []string{"kubectl", "describe", "pods"}, true},
+		{"anchored at start", []string{`get pods`}, []string{"kubectl", "get", "pods"}, false},
+		{"anchored at end", []string{`kubectl get`}, []string{"kubectl", "get", "pods"}, false},
+		{"alternation is anchored", []string{`helm|kubectl`}, []string{"kubectl", "get"}, false},
+		{"whole path", []string{`kubectl .*`}, []string{"kubectl", "get", "pods"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := buildCommandTree(tt.commandNames...)
+			assert.Equal(t, tt.expected, AllowCmdsMatchingRegexp(tt.exprs...)(cmd))
+			assert.Equal(t, !tt.expected, ExcludeCmdsMatchingRegexp(tt.exprs...)(cmd))
+		})
+	}
+
+	assert.Panics(t, func() { AllowCmdsMatchingRegexp("(") })
+}
+
+func TestAllowFlagsMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		globs    []string
+		flagName string
+		expected bool
+	}{
+		{"suffix", []string{"*-timeout"}, "request-timeout", true},
+		{"suffix needs prefix", []string{"*-timeout"}, "timeout", false},
+		{"contains", []string{"*token*"}, "id-token-file", true},
+		{"exact", []string{"output"}, "output", true},
+		{"character class", []string{"[ab]ll"}, "all", true},
+		{"no match", []string{"*-timeout", "output"}, "namespace", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := &pflag.Flag{Name: tt.flagName}
+			assert.Equal(t, tt.expected, AllowFlagsMatching(tt.globs...)(flag))
+			assert.Equal(t, !tt.expected, ExcludeFlagsMatching(tt.globs...)(flag))
+		})
+	}
+
+	assert.Panics(t, func() { AllowFlagsMatching("[") })
+}
+
+func TestAllowFlagsMatchingRegexp(t *testing.T) {
+	flag := &pflag.Flag{Name: "namespace"}
+	assert.True(t, AllowFlagsMatchingRegexp(`(name)?space`)(flag))
+	assert.False(t, AllowFlagsMatchingRegexp(`name`)(flag))
+	assert.False(t, ExcludeFlagsMatchingRegexp(`.*space`)(flag))
+	assert.True(t, ExcludeFlagsMatchingRegexp(`.*(password|secret).*`)(flag))
+}
+
+func TestAllowCmdsWithAnnotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{
+			name:        "matches value",
+			annotations: map[string]string{"mcp": "true"},
+			expected:    true,
+		},
+		{
+			name:        "rejects other value",
+			annotations: map[string]string{"mcp": "false"},
+			expected:    false,
+		},
+		{
+			name:        "rejects missing annotation",
+			annotations: map[string]string{"other": "true"},
+			expected:    false,
+		},
+		{
+			name:        "rejects no annotations",
+			annotations: nil,
+			expected:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := buildCommandTree("cli", "cmd")
+			cmd.Annotations = tt.annotations
+			assert.Equal(t, tt.expected, AllowCmdsWithAnnotation("mcp", "true")(cmd))
+		})
+	}
+}
+
+func TestAllowCmdsInGroup(t *testing.T) {
+	root := &cobra.Command{Use: "kubectl"}
+	root.AddGroup(&cobra.Group{ID: "basic", Title: "Basic Commands"}, &cobra.Group{ID: "admin", Title: "Admin Commands"})
+	get := &cobra.Command{Use: "get", GroupID: "basic", Run: func(_ *cobra.Command, _ []string) {}}
+	pods := &cobra.Command{Use: "pods", Run: func(_ *cobra.Command, _ []string) {}}
+	get.AddCommand(pods)
+	drain := &cobra.Command{Use: "drain", GroupID: "admin", Run: func(_ *cobra.Command, _ []string) {}}
+	version := &cobra.Command{Use: "version", Run: func(_ *cobra.Command, _ []string) {}}
+	root.AddCommand(get, drain, version)
+
+	tests := []struct {
+		name     string
+		groupIDs []string
+		cmd      *cobra.Command
+		expected bool
+	}{
+		{
+			name:     "matches group",
+			groupIDs: []string{"basic"},
+			cmd:      get,
+			expected: true,
+		},
+		{
+			name:     "matches group of parent",
+			groupIDs: []string{"basic"},
+			cmd:      pods,
+			expected: true,
+		},
+		{
+			name:     "rejects other group",
+			groupIDs: []string{"basic"},
+			cmd:      drain,
+			expected: false,
+		},
+		{
+			name:     "matches any listed group",
+			groupIDs: []string{"basic", "admin"},
+			cmd:      drain,
+			expected: true,
+		},
+		{
+			name:     "rejects ungrouped command",
+			groupIDs: []string{"basic"},
+			cmd:      version,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, AllowCmdsInGroup(tt.groupIDs...)(tt.cmd))
+		})
+	}
+}
+
+func TestAnnotationHintSelectors(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		readOnly       bool
+		nonDestructive bool
+	}{
+		{
+			name:           "no annotations",
+			annotations:    nil,
+			readOnly:       false,
+			nonDestructive: false,
+		},
+		{
+			name:           "read only",
+			annotations:    map[string]string{AnnotationReadOnly: "true"},
+			readOnly:       true,
+			nonDestructive: true,
+		},
+		{
+			name:           "not read only",
+			annotations:    map[string]string{AnnotationReadOnly: "false"},
+			readOnly:       false,
+			nonDestructive: false,
+		},
+		{
+			name:           "destructive",
+			annotations:    map[string]string{AnnotationDestructive: "true"},
+			readOnly:       false,
+			nonDestructive: false,
+		},
+		{
+			name:           "not destructive",
+			annotations:    map[string]string{AnnotationDestructive: "false"},
+			readOnly:       false,
+			nonDestructive: true,
+		},
+		{
+			name:           "read only overrides destructive",
+			annotations:    map[string]string{AnnotationReadOnly: "1", AnnotationDestructive: "true"},
+			readOnly:       true,
+			nonDestructive: true,
+		},
+		{
+			name:           "invalid values",
+			annotations:    map[string]string{AnnotationReadOnly: "yes", AnnotationDestructive: "maybe"},
+			readOnly:       false,
+			nonDestructive: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := buildCommandTree("cli", "cmd")
+			cmd.Annotations = tt.annotations
+			assert.Equal(t, tt.readOnly, AllowReadOnlyCmds()(cmd))
+			assert.Equal(t, tt.nonDestructive, ExcludeDestructiveCmds()(cmd))
+		})
+	}
+}
@@ -8,7 +8,8 @@ import (
 
 // startCommandFlags holds flags for the start command.
 type startCommandFlags struct {
-	logLevel string
+	logLevel      string
+	selectorsFile string
 }
 
 // startCommand creates the 'mcp start' command.
@@ -33,6 +34,10 @@ func startCommand(config *Config) *cobra.Command {
 				config.SloggerOptions.Level = level
 			}
 
+			if f.selectorsFile != "" {
+				config.SelectorsFile = f.selectorsFile
+			}
+
 			// Create and start the server
 			return config.serveStdio(cmd)
 		},
@@ -41,5 +46,6 @@ func startCommand(config *Config) *cobra.Command {
 	// Add flags
 	flags := cmd.Flags()
 	flags.StringVar(&f.logLevel, "log-level", "", "Log level (debug, info, warn, error)")
+	flags.StringVar(&f.selectorsFile, "selectors-file", "", "YAML or JSON file of selectors that narrows the exposed tools")
 	return cmd
 }
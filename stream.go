@@ -9,9 +9,10 @@ import (
 
 // streamCommand holds flags for the stream command.
 type streamCommandFlags struct {
-	logLevel string
-	host     string
-	port     int
+	logLevel      string
+	selectorsFile string
+	host          string
+	port          int
 }
 
 // startCommand creates the 'mcp start' command.
@@ -36,6 +37,10 @@ func streamCommand(config *Config) *cobra.Command {
 				config.SloggerOptions.Level = level
 			}
 
+			if f.selectorsFile != "" {
+				config.SelectorsFile = f.selectorsFile
+			}
+
 			// Create and start the server
 			return config.serveHTTP(cmd, fmt.Sprintf("%s:%d", f.host, f.port))
 		},
@@ -44,6 +49,7 @@ func streamCommand(config *Config) *cobra.Command {
 	// Add flags
 	flags := cmd.Flags()
 	flags.StringVar(&f.logLevel, "log-level", "", "Log level (debug, info, warn, error)")
+	flags.StringVar(&f.selectorsFile, "selectors-file", "", "YAML or JSON file of selectors that narrows the exposed tools")
 	flags.StringVar(&f.host, "host", "", "host to listen on")
 	flags.IntVar(&f.port, "port", 8080, "port number to listen on")
 	return cmd
@@ -1,11 +1,14 @@
 package ophis
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
@@ -34,6 +37,10 @@ func toolCommand(config *Config) *cobra.Command {
 				config.SloggerOptions.Level = parseLogLevel(toolFlags.logLevel)
 			}
 
+			if err := config.applySelectorsFile(); err != nil {
+				return err
+			}
+
 			file, err := os.OpenFile("mcp-tools.json", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
 			if err != nil {
 				return fmt.Errorf("failed to create or open mcp-tools.json file: %w", err)
@@ -46,13 +53,17 @@ func toolCommand(config *Config) *cobra.Command {
 
 			encoder := json.NewEncoder(file)
 			encoder.SetIndent("", "  ")
-			config.registerTools(cmd)
+			if err := config.registerTools(cmd); err != nil {
+				return err
+			}
+
 			err = encoder.Encode(config.tools)
 			if err != nil {
 				return fmt.Errorf("failed to encode MCP tools to JSON: %w", err)
 			}
 
 			cmd.Printf("Successfully exported %d tools to mcp-tools.json\n", len(config.tools))
+			printTokenEstimates(cmd, config.tools)
 			return nil
 		},
 	}
@@ -62,3 +73,27 @@ func toolCommand(config *Config) *cobra.Command {
 	flags.StringVar(&toolFlags.logLevel, "log-level", "", "Log level (debug, info, warn, error)")
 	return cmd
 }
+
+// printTokenEstimates prints the estimated token cost of the tools, largest first,
+// to help tune Config.MaxDescriptionLength and Config.MaxFlagDescriptionLength.
+func printTokenEstimates(cmd *cobra.Command, tools []*mcp.Tool) {
+	type estimate struct {
+		name   string
+		tokens int
+	}
+
+	estimates := make([]estimate, 0, len(tools))
+	total := 0
+	for _, tool := range tools {
+		tokens := estimateTokens(tool)
+		estimates = append(estimates, estimate{name: tool.Name, tokens: tokens})
+		total += tokens
+	}
+
+	slices.SortStableFunc(estimates, func(a, b estimate) int { return cmp.Compare(b.tokens, a.tokens) })
+
+	cmd.Printf("Estimated tokens: ~%d total\n", total)
+	for _, e := range estimates {
+		cmd.Printf("%8d  %s\n", e.tokens, e.name)
+	}
+}
@@ -0,0 +1,290 @@
+package ophis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ValidationIssue describes a single problem with the arguments of a tool call.
+type ValidationIssue struct {
+	// Path locates the problem in the arguments, e.g. "flags.replicas" or "args".
+	Path string `json:"path"`
+	// Message explains the problem.
+	Message string `json:"message"`
+	// Suggestion is the closest valid flag name, for unknown flags.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ValidationError describes the arguments of a tool call that do not match the tool's
+// input schema. Its Error text is the content of the tool error result, and its issues
+// are reported in the validationIssues field of the structured content.
+type ValidationError struct {
+	Tool   string            `json:"tool"`
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// Error implements error. It lists one issue per line.
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid arguments for tool %q:", e.Tool)
+	for _, issue := range e.Issues {
+		fmt.Fprintf(&b, "\n- %s: %s", issuePath(issue.Path), issue.Message)
+		if issue.Suggestion != "" {
+			fmt.Fprintf(&b, " (did you mean %q?)", issue.Suggestion)
+		}
+	}
+
+	return b.String()
+}
+
+// issuePath returns the path of an issue for display, naming the empty path.
+func issuePath(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+
+	return path
+}
+
+// inputValidator validates tool call arguments against a tool's input schema.
+// Flags are validated one at a time so every offending flag is reported,
+// then the flags object, the args array, and the whole input are validated.
+type inputValidator struct {
+	flagNames []string
+	flags     map[string]*jsonschema.Resolved // per flag
+	flagsObj  *jsonschema.Resolved            // required flags and flag groups
+	groups    []describedSchema               // flag groups, to explain flagsObj failures
+	args      *jsonschema.Resolved
+	root      *jsonschema.Resolved
+}
+
+// describedSchema is a resolved schema with the description to report when it fails.
+type describedSchema struct {
+	description string
+	resolved    *jsonschema.Resolved
+}
+
+// newInputValidator resolves the schemas of a tool's input schema.
+func newInputValidator(schema *jsonschema.Schema) (*inputValidator, error) {
+	v := &inputValidator{flags: make(map[string]*jsonschema.Resolved)}
+
+	var err error
+	if v.root, err = schema.Resolve(nil); err != nil {
+		return nil, err
+	}
+
+	if args := schema.Properties["args"]; args != nil {
+		if v.args, err = args.Resolve(nil); err != nil {
+			return nil, fmt.Errorf("args: %w", err)
+		}
+	}
+
+	flagsSchema := schema.Properties["flags"]
+	if flagsSchema == nil {
+		return v, nil
+	}
+
+	if v.flagsObj, err = flagsSchema.Resolve(nil); err != nil {
+		return nil, fmt.Errorf("flags: %w", err)
+	}
+
+	for _, group := range flagsSchema.AllOf {
+		if group.Description == "" {
+			continue
+		}
+
+		resolved, err := group.Resolve(nil)
+		if err != nil {
+			return nil, fmt.Errorf("flag group: %w", err)
+		}
+
+		v.groups = append(v.groups, describedSchema{description: group.Description, resolved: resolved})
+	}
+
+	for name, flagSchema := range flagsSchema.Properties {
+		if v.flags[name], err = flagSchema.Resolve(nil); err != nil {
+			return nil, fmt.Errorf("flag %q: %w", name, err)
+		}
+
+		v.flagNames = append(v.flagNames, name)
+	}
+
+	slices.Sort(v.flagNames)
+	return v, nil
+}
+
+// validate returns the issues found in the raw arguments of a tool call.
+func (v *inputValidator) validate(arguments json.RawMessage) []ValidationIssue {
+	input := map[string]any{}
+	if len(arguments) > 0 && string(arguments) != "null" {
+		if err := json.Unmarshal(arguments, &input); err != nil {
+			return []ValidationIssue{{Message: fmt.Sprintf("arguments must be a JSON object: %v", err)}}
+		}
+	}
+
+	var issues []ValidationIssue
+	if flags, ok := input["flags"].(map[string]any); ok {
+		names := make([]string, 0, len(flags))
+		for name := range flags {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			resolved, known := v.flags[name]
+			switch {
+			case !known:
+				issues = append(issues, ValidationIssue{
+					Path:       "flags." + name,
+					Message:    "unknown flag",
+					Suggestion: closestName(name, v.flagNames),
+				})
+			default:
+				if err := resolved.Validate(flags[name]); err != nil {
+					issues = append(issues, ValidationIssue{Path: "flags." + name, Message: validationMessage(err)})
+				}
+			}
+		}
+
+		if len(issues) == 0 && v.flagsObj != nil {
+			issues = append(issues, v.validateFlagsObject(flags)...)
+		}
+	}
+
+	if args, ok := input["args"]; ok && v.args != nil {
+		if err := v.args.Validate(args); err != nil {
+			issues = append(issues, ValidationIssue{Path: "args", Message: validationMessage(err)})
+		}
+	}
+
+	// Anything left is about the shape of the input itself, such as missing args or flags
+	if len(issues) == 0 {
+		if err := v.root.Validate(input); err != nil {
+			issues = append(issues, ValidationIssue{Message: validationMessage(err)})
+		}
+	}
+
+	return issues
+}
+
+// validateFlagsObject validates the flags object as a whole, for required flags and flag groups.
+// Failed flag groups are reported by their descriptions, which name the flags involved.
+func (v *inputValidator) validateFlagsObject(flags map[string]any) []ValidationIssue {
+	err := v.flagsObj.Validate(flags)
+	if err == nil {
+		return nil
+	}
+
+	var issues []ValidationIssue
+	for _, group := range v.groups {
+		if group.resolved.Validate(flags) != nil {
+			issues = append(issues, ValidationIssue{Path: "flags", Message: group.description})
+		}
+	}
+
+	if len(issues) == 0 {
+		issues = append(issues, ValidationIssue{Path: "flags", Message: validationMessage(err)})
+	}
+
+	return issues
+}
+
+// validationMessage strips the "validating ..." location prefixes that the
+// jsonschema-go validator adds, which are meaningless to callers.
+func validationMessage(err error) string {
+	msg := err.Error()
+	for strings.HasPrefix(msg, "validating ") {
+		_, rest, ok := strings.Cut(msg, ": ")
+		if !ok {
+			break
+		}
+
+		msg = rest
+	}
+
+	return msg
+}
+
+// closestName returns the name in names closest to name by edit distance,
+// or "" if none is close enough to be a plausible typo.
+func closestName(name string, names []string) string {
+	best, bestDist := "", -1
+	for _, candidate := range names {
+		d := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+
+	// Allow roughly one edit per three characters, and at least two
+	if bestDist < 0 || bestDist > max(2, len(name)/3) {
+		return ""
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// validationMiddleware validates the arguments of calls to registered tools before
+// their handlers run, and answers invalid calls with a tool error that lists the issues.
+// Without it, the SDK would reject invalid calls with a single protocol error, and the
+// model would not learn what to fix. The structured content is a ToolOutput, so the
+// result still matches the output schema.
+func (c *Config) validationMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		call, ok := req.(*mcp.CallToolRequest)
+		if !ok || method != "tools/call" || call.Params == nil {
+			return next(ctx, method, req)
+		}
+
+		entry, ok := c.toolEntries[call.Params.Name]
+		if !ok || entry.validator == nil {
+			return next(ctx, method, req)
+		}
+
+		issues := entry.validator.validate(call.Params.Arguments)
+		if len(issues) == 0 {
+			return next(ctx, method, req)
+		}
+
+		verr := &ValidationError{Tool: call.Params.Name, Issues: issues}
+		slog.Warn("tool call arguments failed validation", "name", call.Params.Name, "issues", len(issues))
+		return &mcp.CallToolResult{
+			IsError:           true,
+			Content:           []mcp.Content{&mcp.TextContent{Text: verr.Error()}},
+			StructuredContent: ToolOutput{ExitCode: -1, ValidationIssues: issues},
+		}, nil
+	}
+}
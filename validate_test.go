@@ -0,0 +1,175 @@
+package ophis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validateTestCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "scale", Args: cobra.ExactArgs(1), Run: func(_ *cobra.Command, _ []string) {}}
+	cmd.Flags().Int("replicas", 1, "Number of replicas")
+	cmd.Flags().String("namespace", "", "Namespace")
+	cmd.Flags().Bool("json", false, "JSON output")
+	cmd.Flags().Bool("yaml", false, "YAML output")
+	cmd.MarkFlagsMutuallyExclusive("json", "yaml")
+	return cmd
+}
+
+func TestInputValidator(t *testing.T) {
+	tool := Selector{}.createToolFromCmd(validateTestCmd(), "kubectl")
+	v, err := newInputValidator(tool.InputSchema.(*jsonschema.Schema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		arguments string
+		want      []ValidationIssue
+	}{
+		{
+			name:      "valid",
+			arguments: `{"flags": {"replicas": 3}, "args": ["web"]}`,
+		},
+		{
+			name:      "unknown flags with suggestions",
+			arguments: `{"flags": {"replica": 3, "Namespace": "x", "force": true}, "args": ["web"]}`,
+			want: []ValidationIssue{
+				{Path: "flags.Namespace", Message: "unknown flag", Suggestion: "namespace"},
+				{Path: "flags.force", Message: "unknown flag"},
+				{Path: "flags.replica", Message: "unknown flag", Suggestion: "replicas"},
+			},
+		},
+		{
+			name:      "wrong flag types are all reported",
+			arguments: `{"flags": {"replicas": "three", "namespace": 1}, "args": ["web"]}`,
+			want: []ValidationIssue{
+				{Path: "flags.namespace"},
+				{Path: "flags.replicas"},
+			},
+		},
+		{
+			name:      "flag group",
+			arguments: `{"flags": {"json": true, "yaml": true}, "args": ["web"]}`,
+			want:      []ValidationIssue{{Path: "flags", Message: "at most one of the flags json, yaml may be set"}},
+		},
+		{
+			name:      "too many args",
+			arguments: `{"flags": {}, "args": ["web", "db"]}`,
+			want:      []ValidationIssue{{Path: "args"}},
+		},
+		{
+			name:      "missing args",
+			arguments: `{"flags": {}}`,
+			want:      []ValidationIssue{{Path: ""}},
+		},
+		{
+			name:      "not an object",
+			arguments: `[1]`,
+			want:      []ValidationIssue{{Path: ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := v.validate(json.RawMessage(tt.arguments))
+			require.Len(t, issues, len(tt.want), "%v", issues)
+			for i, want := range tt.want {
+				assert.Equal(t, want.Path, issues[i].Path)
+				assert.Equal(t, want.Suggestion, issues[i].Suggestion)
+				assert.NotEmpty(t, issues[i].Message)
+				if want.Message != "" {
+					assert.Equal(t, want.Message, issues[i].Message)
+				}
+			}
+		})
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	names := []string{"namespace", "output", "replicas", "all"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"replica", "replicas"},
+		{"ouptut", "output"},
+		{"NAMESPACE", "namespace"},
+		{"al", "all"},
+		{"force", ""},
+		{"x", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, closestName(tt.name, names))
+		})
+	}
+
+	assert.Equal(t, "", closestName("any", nil))
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("flag", "flag"))
+	assert.Equal(t, 1, editDistance("flag", "flags"))
+	assert.Equal(t, 2, editDistance("ouptut", "output"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 4, editDistance("", "four"))
+}
+
+func TestValidationErrorResult(t *testing.T) {
+	root := &cobra.Command{Use: "kubectl"}
+	root.AddCommand(validateTestCmd())
+
+	c := &Config{ExecutionMode: ExecutionModeInProcess}
+	c.registerTools(root)
+
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := c.server.Connect(ctx, serverTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = serverSession.Close() }()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	require.NoError(t, err)
+	defer func() { _ = session.Close() }()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "kubectl_scale",
+		Arguments: map[string]any{"flags": map[string]any{"replica": 3}, "args": []string{"web"}},
+	})
+	require.NoError(t, err)
+	assert.True(t, res.IsError)
+	require.Len(t, res.Content, 1)
+	assert.Contains(t, res.Content[0].(*mcp.TextContent).Text, `- flags.replica: unknown flag (did you mean "replicas"?)`)
+
+	// The structured content is a ToolOutput, so it matches the tool's output schema
+	data, err := json.Marshal(res.StructuredContent)
+	require.NoError(t, err)
+	var out ToolOutput
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, ToolOutput{
+		ExitCode:         -1,
+		ValidationIssues: []ValidationIssue{{Path: "flags.replica", Message: "unknown flag", Suggestion: "replicas"}},
+	}, out)
+
+	var instance any
+	require.NoError(t, json.Unmarshal(data, &instance))
+	resolved, err := outputSchema.Copy().Resolve(nil)
+	require.NoError(t, err)
+	assert.NoError(t, resolved.Validate(instance))
+
+	// Valid calls reach the command
+	res, err = session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "kubectl_scale",
+		Arguments: map[string]any{"flags": map[string]any{"replicas": 3}, "args": []string{"web"}},
+	})
+	require.NoError(t, err)
+	assert.False(t, res.IsError)
+}